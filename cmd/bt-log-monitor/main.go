@@ -16,10 +16,13 @@ import (
 	"os/signal"
 	"path"
 	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/package-url/packageurl-go"
+	"github.com/haydentherapper/bt-log/internal/entry"
+	"github.com/haydentherapper/bt-log/internal/flags"
+	bt_purl "github.com/haydentherapper/bt-log/internal/purl"
 	tlog "github.com/transparency-dev/formats/log"
 	"github.com/transparency-dev/merkle/proof"
 	"github.com/transparency-dev/merkle/rfc6962"
@@ -40,6 +43,8 @@ var (
 	purlNamespaceRegex = flag.String("purl-namespace-regex", "", "Regex to match pURL namespace. Must set all pURL regex if set")
 	purlNameRegex      = flag.String("purl-name-regex", "", "Regex to match pURL name. Must set all pURL regex if set")
 	purlVersionRegex   = flag.String("purl-version-regex", "", "Regex to match pURL version. Must set all pURL regex if set")
+	checksumAlgs       = flag.String("checksum-algorithms", "sha256", "Comma-separated list of allowed pURL checksum algorithms, e.g. sha256,sha512")
+	followRotations    = flag.Bool("follow-key-rotations", true, "Whether to trust a new log key when the trusted key has endorsed it in a key rotation statement")
 )
 
 func errAttr(err error) slog.Attr {
@@ -73,6 +78,7 @@ func main() {
 		slog.Error("--storage-dir must be set")
 		os.Exit(1)
 	}
+	allowedChecksumAlgs := flags.SplitList(*checksumAlgs)
 	regexMatch := false
 	if *purlTypeRegex != "" && *purlNamespaceRegex != "" && *purlNameRegex != "" && *purlVersionRegex != "" {
 		regexMatch = true
@@ -99,15 +105,20 @@ func main() {
 			return
 		}
 
-		// Create checkpoint verifier using log public key
-		pubKey, err := os.ReadFile(*pubKeyPath)
+		// Create checkpoint verifier using log public key, or the key it was last rotated to
+		keyPath := *pubKeyPath
+		trustedKeyPath := path.Join(*storageDir, "trusted-key")
+		if _, err := os.Stat(trustedKeyPath); err == nil && *followRotations {
+			keyPath = trustedKeyPath
+		}
+		pubKey, err := os.ReadFile(keyPath)
 		if err != nil {
-			slog.Error("failed to read public key file", "file", *pubKeyPath, errAttr(err))
+			slog.Error("failed to read public key file", "file", keyPath, errAttr(err))
 			return
 		}
 		v, err := note.NewVerifier(string(pubKey))
 		if err != nil {
-			slog.Error("failed to initialize checkpoint verifier", "file", *pubKeyPath, errAttr(err))
+			slog.Error("failed to initialize checkpoint verifier", "file", keyPath, errAttr(err))
 			return
 		}
 
@@ -161,6 +172,27 @@ func main() {
 			return
 		}
 		latestCP, _, _, err := tlog.ParseCheckpoint(latestCPBytes, v.Name(), v)
+		rotatedKey := ""
+		if err != nil && *followRotations {
+			// The log may have rotated its key, which is trusted only if the trusted key endorsed it
+			newKey, rotErr := followKeyRotations(context.Background(), lURL, string(pubKey))
+			if rotErr != nil {
+				slog.Error("error following log key rotations", errAttr(rotErr))
+				return
+			}
+			if newKey != strings.TrimSpace(string(pubKey)) {
+				newV, vErr := note.NewVerifier(newKey)
+				if vErr != nil {
+					slog.Error("failed to initialize checkpoint verifier for rotated key", errAttr(vErr))
+					return
+				}
+				latestCP, _, _, err = tlog.ParseCheckpoint(latestCPBytes, newV.Name(), newV)
+				if err == nil {
+					slog.Info("following log key rotation", "old-key", strings.TrimSpace(string(pubKey)), "new-key", newKey)
+					rotatedKey = newKey
+				}
+			}
+		}
 		if err != nil {
 			slog.Error("failed to verify latest checkpoint", errAttr(err))
 			return
@@ -195,70 +227,78 @@ func main() {
 			}
 			// Iterate over each entry in the bundle, which may be from a partial tile
 			for _, e := range entries.Entries[eb.First:] {
-				// Parse pURL string
-				purl, err := packageurl.FromString(string(e))
-				if err != nil {
-					slog.Error("error parsing pURL", "purl", string(e), "tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
+				// Parse log entry, which may be a raw pURL string for older entries
+				var purl entry.Entry
+				if err := purl.Unmarshal(e); err != nil {
+					slog.Error("error parsing entry", "entry", string(e), "tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
 					return
 				}
-				slog.Debug("New entry", "purl", purl.String(), "tile-index", eb.Index, "log-size", latestCP.Size)
+				slog.Debug("New entry", "purl", purl.PURL(), "filename", purl.Filename, "publisher", purl.Publisher, "publisher-key-id", purl.PublisherKeyID,
+					"tile-index", eb.Index, "log-size", latestCP.Size)
 
 				// Log if entry matches provided regex
 				if regexMatch {
 					typeMatch, err := regexp.MatchString(*purlTypeRegex, purl.Type)
 					if err != nil {
-						slog.Error("error matching pURL", "purl", purl.String(),
+						slog.Error("error matching pURL", "purl", purl.PURL(),
 							"matcher", "type", "value", purl.Type, "regex", *purlTypeRegex,
 							"tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
 						return
 					}
 					namespaceMatch, err := regexp.MatchString(*purlNamespaceRegex, purl.Namespace)
 					if err != nil {
-						slog.Error("error matching pURL", "purl", purl.String(),
+						slog.Error("error matching pURL", "purl", purl.PURL(),
 							"matcher", "namespace", "value", purl.Namespace, "regex", *purlNamespaceRegex,
 							"tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
 						return
 					}
 					nameMatch, err := regexp.MatchString(*purlNameRegex, purl.Name)
 					if err != nil {
-						slog.Error("error matching pURL", "purl", purl.String(),
+						slog.Error("error matching pURL", "purl", purl.PURL(),
 							"matcher", "name", "value", purl.Name, "regex", *purlNameRegex,
 							"tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
 						return
 					}
 					versionMatch, err := regexp.MatchString(*purlVersionRegex, purl.Version)
 					if err != nil {
-						slog.Error("error matching pURL", "purl", purl.String(),
+						slog.Error("error matching pURL", "purl", purl.PURL(),
 							"matcher", "version", "value", purl.Version, "regex", *purlVersionRegex,
 							"tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
 						return
 					}
 					if typeMatch && namespaceMatch && nameMatch && versionMatch {
-						slog.Info("Entry found", "purl", purl.String(), "tile-index", eb.Index, "log-size", latestCP.Size)
+						slog.Info("Entry found", "purl", purl.PURL(), "tile-index", eb.Index, "log-size", latestCP.Size)
 					}
 				}
 
 				// Verify 1-1 mapping between package ID and checksum
-				checksum, ok := purl.Qualifiers.Map()["checksum"]
-				if !ok {
-					slog.Error("error getting checksum from pURL", "purl", purl.String,
+				checksum := purl.Checksum()
+				if _, err := bt_purl.ParseChecksums(checksum, allowedChecksumAlgs); err != nil {
+					slog.Error("ALERT: invalid checksum for pURL", "purl", purl.PURL(),
 						"tile-index", eb.Index, "log-size", latestCP.Size, errAttr(err))
 					return
 				}
-				purlWithoutChecksum := packageurl.NewPackageURL(purl.Type, purl.Namespace, purl.Name,
-					purl.Version, nil, "").ToString()
-				hash, found := idHashMap[purlWithoutChecksum]
-				if found && checksum != hash {
-					// Log if mapping is no longer 1-1
-					slog.Error(
-						fmt.Sprintf("ALERT: mismatched checksum for purl %s, got %s, expected %s",
-							purlWithoutChecksum, hash, checksum),
-						"purl", purl.String())
-					return
-				} else {
-					// Persist new mapping
-					idHashMap[purlWithoutChecksum] = checksum
+				purlWithoutChecksum := purl.ID()
+				if hash, found := idHashMap[purlWithoutChecksum]; found {
+					match, err := bt_purl.ChecksumsMatch(hash, checksum)
+					if errors.Is(err, bt_purl.ErrNoSharedChecksum) {
+						// Checksums with no algorithm in common can't be compared, so the
+						// checksum already seen is kept for later entries
+						slog.Warn("checksum for purl shares no algorithm with the checksum already seen",
+							"purl", purl.PURL(), "expected", hash, "tile-index", eb.Index, "log-size", latestCP.Size)
+						continue
+					}
+					if !match {
+						// Log if mapping is no longer 1-1
+						slog.Error(
+							fmt.Sprintf("ALERT: mismatched checksum for purl %s, got %s, expected %s",
+								purlWithoutChecksum, hash, checksum),
+							"purl", purl.PURL())
+						return
+					}
 				}
+				// Persist new mapping
+				idHashMap[purlWithoutChecksum] = checksum
 			}
 		}
 
@@ -271,6 +311,13 @@ func main() {
 			slog.Error("error writing latest checkpoint", errAttr(err))
 			return
 		}
+		// Persist the rotated key, which the latest checkpoint is signed by
+		if rotatedKey != "" {
+			if err := os.WriteFile(trustedKeyPath, []byte(rotatedKey), 0o644); err != nil {
+				slog.Error("error writing rotated log key", errAttr(err))
+				return
+			}
+		}
 
 		// Persist encoded packge ID -> hash map
 		var buffer bytes.Buffer
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/haydentherapper/bt-log/internal/rotation"
+)
+
+// keyRotationsResponse is the response from the log's /key-rotations endpoint
+type keyRotationsResponse struct {
+	Statements [][]byte `json:"statements"`
+}
+
+// followKeyRotations returns the latest log key endorsed by the trusted key through the log's
+// key rotation statements, or the trusted key if the log hasn't rotated it
+func followKeyRotations(ctx context.Context, logURL *url.URL, trusted string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logURL.JoinPath("key-rotations").String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching key rotations: %s", resp.Status)
+	}
+	var r keyRotationsResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		return "", fmt.Errorf("error parsing key rotations: %w", err)
+	}
+	return rotation.Follow(trusted, r.Statements), nil
+}
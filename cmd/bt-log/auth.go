@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/haydentherapper/bt-log/internal/auth"
+	"github.com/haydentherapper/bt-log/internal/entry"
+	"github.com/haydentherapper/bt-log/internal/publisher"
+)
+
+// submitterAuth authenticates the submitters of entries, and authorizes which packages each
+// submitter may log. A nil submitterAuth accepts all entries without a publisher identity.
+type submitterAuth struct {
+	authn  auth.Authenticator
+	policy *auth.Policy
+}
+
+// authenticate returns the identity of the request's submitter. If the submitter can't be
+// authenticated, a 401 is written and false is returned.
+func (a *submitterAuth) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if a == nil {
+		return "", true
+	}
+	identity, err := a.authn.Authenticate(r)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			log.Printf("failed to authenticate submitter: %v", err)
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("submitter must be authenticated"))
+		return "", false
+	}
+	return identity, true
+}
+
+// authorize returns an error if the submitter may not log the entry's package
+func (a *submitterAuth) authorize(identity string, e *entry.Entry) error {
+	if a == nil {
+		return nil
+	}
+	if !a.policy.Allowed(identity, e.Type, e.Namespace, e.Name) {
+		return fmt.Errorf("%s is not authorized to log %s", identity, e.ID())
+	}
+	return nil
+}
+
+// publisherKeyID verifies the optional publisher signature over the submission of the entry by
+// the submitter, and returns the ID of the signing key from the keyring, or an empty ID if the
+// entry isn't signed
+func publisherKeyID(k *publisher.Keyring, e LogEntry, identity string) (string, error) {
+	if e.Signature == nil && e.Note == "" {
+		return "", nil
+	}
+	if k == nil {
+		return "", fmt.Errorf("log does not accept signed submissions")
+	}
+	if e.Signature != nil && e.Note != "" {
+		return "", fmt.Errorf("only one of signature or note must be set")
+	}
+	msg, err := publisher.Submission{PURL: e.PURL, Filename: e.Filename, Submitter: identity}.Message()
+	if err != nil {
+		return "", err
+	}
+	if e.Note != "" {
+		return k.VerifyNote(msg, []byte(e.Note))
+	}
+	return k.VerifySignature(msg, e.Signature)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/entry"
+	"github.com/haydentherapper/bt-log/internal/index"
+	"github.com/haydentherapper/bt-log/internal/purl"
+	f_log "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/api/layout"
+	"github.com/transparency-dev/tessera/client"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// followLog indexes entries by package identity as they are published, polling for a new
+// checkpoint every interval until the context is cancelled
+func followLog(ctx context.Context, s *index.Store, readCheckpoint client.CheckpointFetcherFunc,
+	readEntryBundle client.EntryBundleFetcherFunc, v note.Verifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := updateIndex(ctx, s, readCheckpoint, readEntryBundle, v); err != nil {
+			log.Printf("error updating index: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateIndex indexes all entries between the index size and the latest checkpoint size
+func updateIndex(ctx context.Context, s *index.Store, readCheckpoint client.CheckpointFetcherFunc,
+	readEntryBundle client.EntryBundleFetcherFunc, v note.Verifier) error {
+	size, err := s.Size(ctx)
+	if err != nil {
+		return err
+	}
+	rawCp, err := readCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	cp, _, _, err := f_log.ParseCheckpoint(rawCp, v.Name(), v)
+	if err != nil {
+		return err
+	}
+	if cp.Size <= size {
+		return nil
+	}
+
+	// Persist each entry bundle as it's processed, so progress isn't lost on error
+	for eb := range layout.Range(size, cp.Size-size, cp.Size) {
+		bundle, err := client.GetEntryBundle(ctx, readEntryBundle, eb.Index, cp.Size)
+		if err != nil {
+			return err
+		}
+		var entries []index.Entry
+		for i, e := range bundle.Entries[eb.First : eb.First+eb.N] {
+			var ent entry.Entry
+			if err := ent.Unmarshal(e); err != nil {
+				return err
+			}
+			entries = append(entries, index.Entry{
+				Index:    eb.Index*layout.EntryBundleWidth + uint64(eb.First) + uint64(i),
+				ID:       ent.ID(),
+				Checksum: ent.Checksum(),
+				Data:     e,
+			})
+		}
+		if err := s.Add(ctx, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConflictResponse is returned with a 409 when an entry's checksum differs from the checksum
+// already recorded for the same package identity. Index is omitted if the existing entry
+// has not been sequenced yet.
+type ConflictResponse struct {
+	Error    string  `json:"error"`
+	Checksum string  `json:"checksum"`
+	Index    *uint64 `json:"index,omitempty"`
+}
+
+func writeConflictResp(w http.ResponseWriter, conflict *ConflictResponse) {
+	jResp, err := json.Marshal(conflict)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	if _, err := w.Write(jResp); err != nil {
+		log.Printf("error writing conflict response: %v", err)
+	}
+}
+
+// checksumGuard enforces a 1-1 mapping between package identity and checksum when entries
+// are submitted, so that conflicting entries are never logged. A nil checksumGuard accepts
+// all entries.
+type checksumGuard struct {
+	s *index.Store
+}
+
+// reserve records the entry's checksum for its package identity, returning a ConflictResponse
+// if a different checksum has already been recorded. reserved is set if this call recorded the
+// checksum, so the reservation can be released if the entry isn't added. An entry whose
+// checksums share no algorithm with the recorded checksum can't be compared, so it's rejected
+// with a 422 rather than as a conflict.
+func (g *checksumGuard) reserve(ctx context.Context, e *entry.Entry) (reserved bool, conflict *ConflictResponse, err error) {
+	if g == nil {
+		return false, nil, nil
+	}
+	id, checksum := e.ID(), e.Checksum()
+	p, err := g.s.Reserve(ctx, id, checksum)
+	if err != nil {
+		return false, nil, err
+	}
+	// Entries may list several checksums, which conflict if any digest differs for a shared
+	// algorithm
+	match, err := purl.ChecksumsMatch(p.Checksum, checksum)
+	if errors.Is(err, purl.ErrNoSharedChecksum) {
+		return false, nil, &submitError{
+			status: http.StatusUnprocessableEntity,
+			err: fmt.Errorf("%s already logged with checksum %s, which shares no algorithm with %s",
+				id, p.Checksum, checksum),
+		}
+	}
+	if !match {
+		return false, &ConflictResponse{
+			Error:    fmt.Sprintf("%s already logged with checksum %s", id, p.Checksum),
+			Checksum: p.Checksum,
+			Index:    p.Index,
+		}, nil
+	}
+	return p.Reserved, nil, nil
+}
+
+// release removes the reservation of an entry's checksum that wasn't sequenced
+func (g *checksumGuard) release(ctx context.Context, e *entry.Entry) {
+	if g == nil {
+		return
+	}
+	if err := g.s.Release(ctx, e.ID(), e.Checksum()); err != nil {
+		log.Printf("error releasing checksum reservation for %s: %v", e.ID(), err)
+	}
+}
+
+// resolve waits for a reserved entry to be sequenced and records its index, or releases the
+// reservation if the entry could not be added
+func (g *checksumGuard) resolve(ctx context.Context, e *entry.Entry, f tessera.IndexFuture) {
+	if g == nil {
+		return
+	}
+	idx, err := f()
+	if err != nil {
+		g.release(ctx, e)
+		return
+	}
+	if err := g.s.SetIndex(ctx, e.ID(), idx.Index); err != nil {
+		log.Printf("error recording index for %s: %v", e.ID(), err)
+	}
+}
+
+// entryDedup finds entries identical to one that has already been sequenced, so that a
+// resubmitted entry returns its original index rather than being logged again, regardless of
+// restarts or how long ago it was logged. A nil entryDedup finds no entries, leaving
+// deduplication to Tessera's antispam.
+type entryDedup struct {
+	s *index.Store
+}
+
+// find returns a future resolving to the original index if an identical entry has been
+// sequenced, or nil if it hasn't
+func (d *entryDedup) find(ctx context.Context, data []byte) (tessera.IndexFuture, error) {
+	if d == nil {
+		return nil, nil
+	}
+	idx, err := d.s.FindIndex(ctx, data)
+	if err != nil || idx == nil {
+		return nil, err
+	}
+	return func() (tessera.Index, error) { return tessera.Index{Index: *idx, IsDup: true}, nil }, nil
+}
+
+// record waits for an entry to be sequenced and records its index, so that it can be found
+// before it's indexed
+func (d *entryDedup) record(ctx context.Context, data []byte, f tessera.IndexFuture) {
+	if d == nil {
+		return
+	}
+	idx, err := f()
+	if err != nil {
+		return
+	}
+	if err := d.s.AddSequenced(ctx, data, idx.Index); err != nil {
+		log.Printf("error recording sequenced entry at index %d: %v", idx.Index, err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/haydentherapper/bt-log/internal/rotation"
+)
+
+// KeyRotationsResponse is returned by /key-rotations. Statements are signed notes, each
+// endorsing a new checkpoint signing key with the key it replaces, in the order configured.
+type KeyRotationsResponse struct {
+	Statements [][]byte `json:"statements"`
+}
+
+// loadKeyRotations reads and verifies key rotation statements for the origin
+func loadKeyRotations(paths []string, origin string) ([][]byte, error) {
+	statements := [][]byte{}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key rotation statement: %w", err)
+		}
+		s, err := rotation.Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key rotation statement %s: %w", p, err)
+		}
+		if s.Origin != origin {
+			return nil, fmt.Errorf("key rotation statement %s is for origin %s, not %s", p, s.Origin, origin)
+		}
+		statements = append(statements, b)
+	}
+	return statements, nil
+}
@@ -2,11 +2,12 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -15,25 +16,60 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/haydentherapper/bt-log/internal/auth"
+	"github.com/haydentherapper/bt-log/internal/config"
+	"github.com/haydentherapper/bt-log/internal/entry"
+	"github.com/haydentherapper/bt-log/internal/flags"
+	"github.com/haydentherapper/bt-log/internal/index"
+	bt_keyring "github.com/haydentherapper/bt-log/internal/keyring"
+	"github.com/haydentherapper/bt-log/internal/publisher"
 	"github.com/haydentherapper/bt-log/internal/purl"
+	"github.com/haydentherapper/bt-log/internal/ratelimit"
+	"github.com/haydentherapper/bt-log/internal/witness"
+	"github.com/package-url/packageurl-go"
 	f_log "github.com/transparency-dev/formats/log"
-	"github.com/transparency-dev/merkle/proof"
 	"github.com/transparency-dev/merkle/rfc6962"
 	"github.com/transparency-dev/tessera"
 	"github.com/transparency-dev/tessera/client"
-	"github.com/transparency-dev/tessera/storage/posix"
+	mysqlantispam "github.com/transparency-dev/tessera/storage/aws/antispam"
+	posixantispam "github.com/transparency-dev/tessera/storage/posix/antispam"
 	"golang.org/x/mod/sumdb/note"
 )
 
 var (
 	host              = flag.String("host", "localhost", "host to listen on")
 	port              = flag.Uint("port", 8080, "port to listen on")
-	storageDir        = flag.String("storage-dir", "", "Root directory to store log data")
-	purlType          = flag.String("purl-type", "", "Restricts pURLs to be of a specific type")
+	storageBackend    = flag.String("storage-backend", "posix", "Log storage backend (posix, mysql, s3)")
+	storageDir        = flag.String("storage-dir", "", "Root directory to store log data. Required for --storage-backend=posix")
+	configFile        = flag.String("config", "", "Optional configuration file location tuning checkpointing, batching and antispam")
+	storageDSN        = flag.String("storage-dsn", "", "MySQL data source name. Required for --storage-backend=mysql and --storage-backend=s3")
+	s3Bucket          = flag.String("s3-bucket", "", "S3 bucket to store log data. Required for --storage-backend=s3")
+	s3Endpoint        = flag.String("s3-endpoint", "", "Optional S3-compatible endpoint for --storage-backend=s3, e.g. a MinIO server")
+	purlTypes         = flag.String("purl-type", "", "Restricts pURLs to a comma-separated list of types, e.g. npm,pypi")
 	privKeyFile       = flag.String("private-key", "", "Location of private key file")
 	pubKeyFile        = flag.String("public-key", "", "Location of public key file")
+	signingKeyring    = flag.String("signing-keyring", "", "Optional keyring file location of checkpoint signing keys, instead of --private-key and --public-key")
+	keyRotations      = flag.String("key-rotations", "", "Optional comma-separated list of key rotation statement file locations to publish")
 	witnessUrl        = flag.String("witness-url", "", "Optional witness to cosign checkpoint")
 	witnessPubKeyFile = flag.String("witness-public-key", "", "Optional witness public key location to verify cosignatures")
+	witnessPolicyFile = flag.String("witness-policy", "", "Optional witness policy file location listing witnesses and the quorum required to publish a checkpoint")
+	indexDBPath       = flag.String("index-db-path", "", "Optional path to a SQLite database for looking up entries by package or leaf hash")
+	uniqueChecksums   = flag.Bool("unique-checksums", false, "Reject entries whose checksum differs from an existing entry for the same package. Requires --index-db-path")
+	maxMergeDelay     = flag.Duration("max-merge-delay", time.Minute, "Deadline for publishing entries added with /add-async")
+	checksumAlgs      = flag.String("checksum-algorithms", "sha256", "Comma-separated list of allowed pURL checksum algorithms, e.g. sha256,sha512")
+	minChecksumBits   = flag.Int("min-checksum-strength", 0, "Minimum strength in bits of at least one pURL checksum, e.g. 128")
+	tlsCertFile       = flag.String("tls-cert", "", "Optional TLS certificate location to serve HTTPS. Requires --tls-key")
+	tlsKeyFile        = flag.String("tls-key", "", "Optional TLS private key location to serve HTTPS. Requires --tls-cert")
+	tlsClientCAFile   = flag.String("tls-client-ca", "", "Optional CA certificates location to authenticate submitters with TLS client certificates. Requires --tls-cert")
+	authTokenFile     = flag.String("auth-token-file", "", "Optional token file location to authenticate submitters with bearer tokens")
+	oidcJWKSFile      = flag.String("oidc-jwks-file", "", "Optional JWKS file location to authenticate submitters with OIDC tokens. Requires --oidc-issuer and --oidc-audience")
+	oidcIssuer        = flag.String("oidc-issuer", "", "Issuer of OIDC tokens")
+	oidcAudience      = flag.String("oidc-audience", "", "Audience of OIDC tokens")
+	authzPolicyFile   = flag.String("authz-policy", "", "Optional policy file location limiting the packages each submitter may log. Requires submitter authentication")
+	publisherKeyring  = flag.String("publisher-keyring", "", "Optional keyring file location of publisher keys, to accept signed submissions")
+	rateLimit         = flag.Float64("rate-limit", 0, "Optional entries per second each submitter may add, by identity or client IP. Tracked in memory per log instance")
+	rateLimitBurst    = flag.Int("rate-limit-burst", maxBatchSize, "Entries a submitter may add at once before --rate-limit applies")
+	namespaceQuota    = flag.Int("namespace-daily-quota", 0, "Optional entries per package namespace per day, resetting at midnight UTC. Counted in memory per log instance, so restarts reset the counts")
 )
 
 func addCacheHeaders(value string, fs http.Handler) http.HandlerFunc {
@@ -44,58 +80,299 @@ func addCacheHeaders(value string, fs http.Handler) http.HandlerFunc {
 }
 
 type LogEntry struct {
-	PURL string `json:"purl"` // e.g. pkg:pypi/pkgname@1.2.3?checksum=sha256:5141b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be92
+	PURL     string `json:"purl"`               // e.g. pkg:pypi/pkgname@1.2.3?checksum=sha256:5141b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be92
+	Filename string `json:"filename,omitempty"` // Optional artifact filename, e.g. pkgname-1.2.3.tar.gz
+	// Optional publisher signature over the submission of the pURL and filename by the submitter,
+	// either a detached Ed25519 signature or a signed note. See publisher.Submission.
+	Signature []byte `json:"signature,omitempty"`
+	Note      string `json:"note,omitempty"`
 }
 
+// LogEntryResponse is returned by /add. Entry is the encoded log entry, whose leaf hash
+// is proven by InclusionProof. Duplicate is set if an identical entry was already logged,
+// in which case Index is the index of the original entry.
 type LogEntryResponse struct {
 	Index          uint64   `json:"index"`
+	Entry          []byte   `json:"entry"`
 	Checkpoint     []byte   `json:"checkpoint"`
 	InclusionProof [][]byte `json:"inclusionProof"`
+	Duplicate      bool     `json:"duplicate,omitempty"`
+}
+
+// InclusionPromiseResponse is returned by /add-async. Promise is a note signed by the log
+// committing to publish the entry at Index before Deadline (unix seconds). Duplicate is set
+// if an identical entry was already sequenced at Index.
+type InclusionPromiseResponse struct {
+	Index     uint64 `json:"index"`
+	Entry     []byte `json:"entry"`
+	LeafHash  []byte `json:"leafHash"`
+	Deadline  int64  `json:"deadline"`
+	Promise   []byte `json:"promise"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// InclusionProofResponse is returned by /proof/inclusion. Checkpoint is only set when the
+// proof is for the latest checkpoint; for an older tree size, the client must already
+// hold a checkpoint of that size.
+type InclusionProofResponse struct {
+	Index          uint64   `json:"index"`
+	TreeSize       uint64   `json:"treeSize"`
+	Checkpoint     []byte   `json:"checkpoint,omitempty"`
+	InclusionProof [][]byte `json:"inclusionProof"`
+}
+
+// LookupEntry is a log entry for a package, with an inclusion proof against the
+// checkpoint in the LookupResponse
+type LookupEntry struct {
+	Index          uint64   `json:"index"`
+	Entry          []byte   `json:"entry"`
+	PURL           string   `json:"purl"`
+	Checksum       string   `json:"checksum"`
+	InclusionProof [][]byte `json:"inclusionProof"`
+}
+
+// LookupResponse is returned by /lookup with all entries for a package identity
+type LookupResponse struct {
+	Checkpoint []byte        `json:"checkpoint"`
+	Entries    []LookupEntry `json:"entries"`
+}
+
+// maxBatchSize is the maximum number of entries accepted in a single /add-batch request
+const maxBatchSize = 256
+
+// BatchLogEntry is an /add-batch request with either a list of pURLs, or a list of entries
+// that may also have filenames and publisher signatures
+type BatchLogEntry struct {
+	PURLs   []string   `json:"purls,omitempty"`
+	Entries []LogEntry `json:"entries,omitempty"`
+}
+
+// BatchLogEntryResult is the result for a single entry in a batch. Either Index, Entry and
+// InclusionProof are set, or Error is set if the entry was rejected or could not be added.
+// Duplicate is set if an identical entry was already logged at Index, including earlier in
+// the same batch.
+type BatchLogEntryResult struct {
+	Index          *uint64  `json:"index,omitempty"`
+	Entry          []byte   `json:"entry,omitempty"`
+	InclusionProof [][]byte `json:"inclusionProof,omitempty"`
+	Duplicate      bool     `json:"duplicate,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// BatchLogEntryResponse contains a result per requested entry, in request order.
+// All inclusion proofs are computed against the same checkpoint.
+type BatchLogEntryResponse struct {
+	Checkpoint []byte                `json:"checkpoint"`
+	Results    []BatchLogEntryResult `json:"results"`
+}
+
+// newEntry returns the log entry and its encoding for a verified pURL
+func newEntry(purlString, filename, publisher, publisherKeyID string) (*entry.Entry, []byte, error) {
+	e, err := entry.FromPURL(purlString)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.Filename = filename
+	e.Publisher = publisher
+	e.PublisherKeyID = publisherKeyID
+	data, err := e.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, data, nil
 }
 
 func main() {
 	flag.Parse()
 
-	if *storageDir == "" {
-		log.Fatalf("--storage-dir must be set")
+	if *storageBackend == "posix" && *storageDir == "" {
+		log.Fatalf("--storage-dir must be set for --storage-backend=posix")
+	}
+	if (*storageBackend == "mysql" || *storageBackend == "s3") && *storageDSN == "" {
+		log.Fatalf("--storage-dsn must be set for --storage-backend=%s", *storageBackend)
 	}
-	if *purlType == "" {
+	if *storageBackend == "s3" && *s3Bucket == "" {
+		log.Fatalf("--s3-bucket must be set for --storage-backend=s3")
+	}
+	if *purlTypes == "" {
 		log.Fatalf("--purl-type must be set")
 	}
-	if *privKeyFile == "" {
-		log.Fatalf("--private-key must be set")
+	if *signingKeyring != "" && (*privKeyFile != "" || *pubKeyFile != "") {
+		log.Fatalf("--signing-keyring can't be used with --private-key or --public-key")
+	}
+	if *signingKeyring == "" && *privKeyFile == "" {
+		log.Fatalf("--private-key or --signing-keyring must be set")
 	}
-	if *pubKeyFile == "" {
+	if *signingKeyring == "" && *pubKeyFile == "" {
 		log.Fatalf("--public-key must be set")
 	}
+	if *uniqueChecksums && *indexDBPath == "" {
+		log.Fatalf("--index-db-path must be set with --unique-checksums")
+	}
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		log.Fatalf("--tls-cert and --tls-key must both be set")
+	}
+	if *tlsClientCAFile != "" && *tlsCertFile == "" {
+		log.Fatalf("--tls-cert must be set with --tls-client-ca")
+	}
+	if *oidcJWKSFile != "" && (*oidcIssuer == "" || *oidcAudience == "") {
+		log.Fatalf("--oidc-issuer and --oidc-audience must be set with --oidc-jwks-file")
+	}
+	if *authzPolicyFile != "" && *tlsClientCAFile == "" && *authTokenFile == "" && *oidcJWKSFile == "" {
+		log.Fatalf("--tls-client-ca, --auth-token-file or --oidc-jwks-file must be set with --authz-policy")
+	}
+	if *rateLimit < 0 || *rateLimitBurst < 1 || *namespaceQuota < 0 {
+		log.Fatalf("--rate-limit and --namespace-daily-quota must not be negative, and --rate-limit-burst must be positive")
+	}
 	if (*witnessUrl != "" && *witnessPubKeyFile == "") ||
 		(*witnessUrl == "" && *witnessPubKeyFile != "") {
 		log.Fatalf("--witness-url and --witness-public-key must both be set")
 	}
+	if *witnessPolicyFile != "" && *witnessUrl != "" {
+		log.Fatalf("only one of --witness-policy or --witness-url must be set")
+	}
+
+	cfg := config.Default()
+	if *configFile != "" {
+		var err error
+		cfg, err = config.Load(*configFile)
+		if err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+	}
+	if *maxMergeDelay <= cfg.MaxSequencingDelay() {
+		log.Fatalf("--max-merge-delay must be longer than the batching max_age plus checkpoint_interval (%v)", cfg.MaxSequencingDelay())
+	}
+	if err := cfg.ValidateBackend(*storageBackend); err != nil {
+		log.Fatalf("invalid config for --storage-backend=%s: %v", *storageBackend, err)
+	}
 
 	ctx := context.Background()
 
-	// Create NoteSigner/Verifier for signing/verifying checkpoints
-	privKey, err := os.ReadFile(*privKeyFile)
-	if err != nil {
-		log.Fatalf("failed to read private key file for %s: %v", *privKeyFile, err)
+	types := flags.SplitList(*purlTypes)
+	if len(types) == 0 {
+		log.Fatalf("--purl-type must contain at least one type")
 	}
-	s, err := note.NewSigner(string(privKey))
+	purlVerifier, err := purl.NewVerifier(types...).WithChecksumPolicy(purl.ChecksumPolicy{
+		Algorithms:  flags.SplitList(*checksumAlgs),
+		MinStrength: *minChecksumBits,
+	})
 	if err != nil {
-		log.Fatalf("failed to read signer %s: %v", *privKeyFile, err)
+		log.Fatalf("invalid checksum policy: %v", err)
+	}
+	// Enforce each package registry's naming and versioning conventions
+	for _, t := range types {
+		purlVerifier.WithRules(t, purl.EcosystemRules(t)...)
+	}
+
+	// Authenticate submitters with any configured credential type
+	var authn auth.Chain
+	var tlsConfig *tls.Config
+	if *tlsClientCAFile != "" {
+		caCerts, err := os.ReadFile(*tlsClientCAFile)
+		if err != nil {
+			log.Fatalf("failed to read client CA file %s: %v", *tlsClientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCerts) {
+			log.Fatalf("no certificates found in client CA file %s", *tlsClientCAFile)
+		}
+		// Client certificates are optional at the TLS layer, so that submitters can use other
+		// credentials and clients can read the log without a certificate
+		tlsConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+		authn = append(authn, auth.CertAuthenticator{})
+	}
+	if *authTokenFile != "" {
+		tokens, err := auth.NewTokenAuthenticator(*authTokenFile)
+		if err != nil {
+			log.Fatalf("failed to load token file: %v", err)
+		}
+		authn = append(authn, tokens)
+	}
+	if *oidcJWKSFile != "" {
+		jwts, err := auth.NewJWTAuthenticator(*oidcJWKSFile, *oidcIssuer, *oidcAudience)
+		if err != nil {
+			log.Fatalf("failed to load JWKS file: %v", err)
+		}
+		authn = append(authn, jwts)
+	}
+	var submitters *submitterAuth
+	if len(authn) > 0 {
+		submitters = &submitterAuth{authn: authn}
+		if *authzPolicyFile != "" {
+			submitters.policy, err = auth.LoadPolicy(*authzPolicyFile)
+			if err != nil {
+				log.Fatalf("failed to load authorization policy: %v", err)
+			}
+		}
+	}
+
+	var keyring *publisher.Keyring
+	if *publisherKeyring != "" {
+		keyring, err = publisher.LoadKeyring(*publisherKeyring)
+		if err != nil {
+			log.Fatalf("failed to load publisher keyring: %v", err)
+		}
+	}
+
+	// Limit how fast each submitter can add entries, and how many entries each package namespace
+	// can add per day, so a misbehaving submitter can't flood the log
+	var limiter *ratelimit.Limiter
+	if *rateLimit > 0 {
+		limiter = ratelimit.NewLimiter(*rateLimit, *rateLimitBurst)
+	}
+	var quota *ratelimit.Quota
+	if *namespaceQuota > 0 {
+		quota = ratelimit.NewQuota(*namespaceQuota)
 	}
 
-	pubKey, err := os.ReadFile(*pubKeyFile)
+	// Create NoteSigners/Verifier for signing/verifying checkpoints. A single private key is a
+	// keyring with only an active key.
+	var keys *bt_keyring.Keyring
+	if *signingKeyring != "" {
+		keys, err = bt_keyring.Load(*signingKeyring, note.NewSigner)
+	} else {
+		keys, err = bt_keyring.New([]bt_keyring.Key{{PrivateKeyPath: *privKeyFile, Active: true}}, note.NewSigner)
+	}
 	if err != nil {
-		log.Fatalf("failed to read public key file for %s: %v", *pubKeyFile, err)
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+	// Keys being rotated out sign alongside the active key until the log restarts after
+	// their overlap period ends
+	signers := keys.Signers(time.Now())
+	s, additionalSigners := signers[0], signers[1:]
+	for _, signer := range additionalSigners {
+		if signer.Name() != s.Name() {
+			log.Fatalf("signing key %s must have the same name as the active key %s", signer.Name(), s.Name())
+		}
+	}
+
+	pubKey := []byte(keys.PublicKeys()[0])
+	if *pubKeyFile != "" {
+		pubKey, err = os.ReadFile(*pubKeyFile)
+		if err != nil {
+			log.Fatalf("failed to read public key file for %s: %v", *pubKeyFile, err)
+		}
 	}
 	v, err := note.NewVerifier(string(pubKey))
 	if err != nil {
 		log.Fatalf("failed to read verifier %s: %v", *pubKeyFile, err)
 	}
 
-	// Create witness
-	var witness *tessera.Witness
+	// Publish key rotation statements, so monitors and witnesses can follow a key rotation
+	rotations, err := loadKeyRotations(flags.SplitList(*keyRotations), s.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the witness group from either a single witness or a witness policy
+	var witnessPolicy *witness.Policy
+	if *witnessPolicyFile != "" {
+		witnessPolicy, err = witness.LoadPolicy(*witnessPolicyFile)
+		if err != nil {
+			log.Fatalf("invalid witness policy: %v", err)
+		}
+	}
 	if *witnessPubKeyFile != "" && *witnessUrl != "" {
 		witnessPubKey, err := os.ReadFile(*witnessPubKeyFile)
 		if err != nil {
@@ -109,24 +386,76 @@ func main() {
 		if err != nil {
 			log.Fatalf("error creating witness: %v", err)
 		}
-		witness = &wit
+		// A single witness is a policy whose quorum is the witness
+		name := wit.Key.Name()
+		witnessPolicy = &witness.Policy{
+			Witnesses: []witness.Witness{{Name: name, PublicKey: string(witnessPubKey), URL: *witnessUrl}},
+			Quorum:    name,
+		}
+	}
+	// Record the outcome of requests to each witness, for /witness/status
+	var witnesses *tessera.WitnessGroup
+	var witnessFailOpen bool
+	var tracker *witness.Tracker
+	var requestsTracked bool
+	httpClient := http.DefaultClient
+	if witnessPolicy != nil {
+		wg, failOpen, err := witnessPolicy.WitnessGroup()
+		if err != nil {
+			log.Fatalf("invalid witness policy: %v", err)
+		}
+		witnesses, witnessFailOpen = &wg, failOpen
+		tracker, err = witness.NewTracker(witnessPolicy, http.DefaultTransport)
+		if err != nil {
+			log.Fatalf("invalid witness policy: %v", err)
+		}
+		httpClient = &http.Client{Transport: tracker}
+		// The MySQL backend requests cosignatures with the default HTTP client, so /witness/status
+		// reports that requests aren't tracked rather than that witnesses haven't been asked
+		requestsTracked = *storageBackend != "mysql"
+		if !requestsTracked {
+			log.Printf("witness request outcomes aren't recorded for /witness/status with --storage-backend=mysql")
+		}
 	}
 
-	// Create the Tessera POSIX storage, using the directory from the --storage-dir flag
-	driver, err := posix.New(ctx, posix.Config{
-		Path: *storageDir,
-	})
+	// Create the Tessera storage, using the directory from the --storage-dir flag for POSIX,
+	// the database from the --storage-dsn flag for MySQL, or the bucket from the --s3-bucket
+	// flag with a MySQL sequencer for S3
+	driver, err := newDriver(ctx, storageConfig{
+		backend:  *storageBackend,
+		dir:      *storageDir,
+		dsn:      *storageDSN,
+		bucket:   *s3Bucket,
+		endpoint: *s3Endpoint,
+	}, httpClient)
 	if err != nil {
 		log.Fatalf("failed to construct driver: %v", err)
 	}
 
+	// Deduplicate entries across restarts with an optional persistent antispam index, on local
+	// disk for POSIX or in MySQL for any backend
+	var as tessera.Antispam
+	switch {
+	case cfg.Antispam.PersistentPath != "":
+		as, err = posixantispam.NewAntispam(ctx, cfg.Antispam.PersistentPath, posixantispam.AntispamOpts{
+			PushbackThreshold: cfg.Antispam.PushbackThreshold,
+		})
+	case cfg.Antispam.PersistentDSN != "":
+		as, err = mysqlantispam.NewAntispam(ctx, cfg.Antispam.PersistentDSN, mysqlantispam.AntispamOpts{
+			PushbackThreshold: cfg.Antispam.PushbackThreshold,
+		})
+	}
+	if err != nil {
+		log.Fatalf("failed to open antispam index: %v", err)
+	}
+
 	opts := tessera.NewAppendOptions().
-		WithCheckpointSigner(s).
-		WithCheckpointInterval(5*time.Second).
-		WithBatching(256, time.Second).
-		WithAntispam(256, nil)
-	if witness != nil {
-		opts = opts.WithWitnesses(tessera.NewWitnessGroup(1, witness), &tessera.WitnessOptions{FailOpen: false})
+		WithCheckpointSigner(s, additionalSigners...).
+		WithCheckpointInterval(cfg.CheckpointInterval.Duration).
+		WithBatching(cfg.Batching.MaxSize, cfg.Batching.MaxAge.Duration).
+		WithAntispam(cfg.Antispam.InMemoryEntries, as)
+	if witnesses != nil {
+		opts = opts.WithWitnesses(*witnesses, &tessera.WitnessOptions{FailOpen: witnessFailOpen})
 	}
 	appender, shutdown, r, err := tessera.NewAppender(ctx, driver, opts)
 	if err != nil {
@@ -134,67 +463,169 @@ func main() {
 	}
 	addFn := appender.Add
 	tileFetcher := r.ReadTile
+	entryBundleFetcher := r.ReadEntryBundle
+	readCheckpoint := r.ReadCheckpoint
 	await := tessera.NewPublicationAwaiter(ctx, r.ReadCheckpoint, time.Second)
 
-	// Define a handler for /add that accepts POST requests and adds the POST body to the log
-	http.HandleFunc("POST /add", func(w http.ResponseWriter, r *http.Request) {
-		b, err := io.ReadAll(r.Body)
+	// Index entries by package identity, and optionally reject entries whose checksum conflicts
+	// with the checksum recorded for the same package identity
+	var idxStore *index.Store
+	if *indexDBPath != "" {
+		idxStore, err = index.New(*indexDBPath)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			log.Fatalf("failed to open index: %v", err)
 		}
+		defer idxStore.Close()
+		go followLog(ctx, idxStore, readCheckpoint, entryBundleFetcher, v, time.Second)
+	}
+	var guard *checksumGuard
+	if *uniqueChecksums {
+		guard = &checksumGuard{s: idxStore}
+	}
+	// Deduplicate resubmitted entries against the index, which covers the log's full history
+	var dedup *entryDedup
+	if idxStore != nil {
+		dedup = &entryDedup{s: idxStore}
+	}
 
-		// Parse request
-		var e LogEntry
-		if err := json.Unmarshal(b, &e); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte(err.Error()))
-			return
-		}
+	handleSubmitAPI(http.DefaultServeMux, &submitAPI{
+		purls:             purlVerifier,
+		auth:              submitters,
+		keyring:           keyring,
+		limiter:           limiter,
+		quota:             quota,
+		guard:             guard,
+		dedup:             dedup,
+		add:               addFn,
+		await:             await,
+		readTile:          tileFetcher,
+		verifier:          v,
+		signer:            s,
+		additionalSigners: additionalSigners,
+		maxMergeDelay:     *maxMergeDelay,
+		ctx:               ctx,
+	})
 
-		if err := purl.VerifyPURL(e.PURL, *purlType); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte(err.Error()))
-			return
-		}
+	handleProofAPI(http.DefaultServeMux, &proofAPI{
+		readCheckpoint:  readCheckpoint,
+		readTile:        tileFetcher,
+		readEntryBundle: entryBundleFetcher,
+		verifier:        v,
+		index:           idxStore,
+	})
+
+	// Define a handler for /lookup that returns all entries for a pURL, ignoring its checksum,
+	// with inclusion proofs
+	if idxStore != nil {
+		http.HandleFunc("GET /lookup", func(w http.ResponseWriter, r *http.Request) {
+			p, err := packageurl.FromString(r.URL.Query().Get("purl"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+
+			entries, err := idxStore.Lookup(r.Context(), purl.PackageID(p))
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			if len(entries) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			// Indexed entries have been published, so they are included in the latest checkpoint
+			rawCp, err := readCheckpoint(r.Context())
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			cp, _, _, err := f_log.ParseCheckpoint(rawCp, v.Name(), v)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			pb, err := client.NewProofBuilder(r.Context(), cp.Size, tileFetcher)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+
+			resp := LookupResponse{Checkpoint: rawCp}
+			for _, e := range entries {
+				var ent entry.Entry
+				if err := ent.Unmarshal(e.Data); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				ip, err := inclusionProof(r.Context(), pb, e.Index, rfc6962.DefaultHasher.HashLeaf(e.Data), cp)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				resp.Entries = append(resp.Entries, LookupEntry{
+					Index:          e.Index,
+					Entry:          e.Data,
+					PURL:           ent.PURL(),
+					Checksum:       e.Checksum,
+					InclusionProof: ip,
+				})
+			}
+
+			jResp, err := json.Marshal(resp)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			if _, err = w.Write(jResp); err != nil {
+				log.Printf("/lookup: %v", err)
+				return
+			}
+		})
+	}
 
-		f := addFn(r.Context(), tessera.NewEntry([]byte(e.PURL)))
-		idx, rawCp, err := await.Await(ctx, f)
+	// Define a handler for /key-rotations that returns the log's key rotation statements
+	http.HandleFunc("GET /key-rotations", func(w http.ResponseWriter, r *http.Request) {
+		jResp, err := json.Marshal(KeyRotationsResponse{Statements: rotations})
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		cp, _, _, err := f_log.ParseCheckpoint(rawCp, v.Name(), v)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte(err.Error()))
-			return
+		if _, err = w.Write(jResp); err != nil {
+			log.Printf("/key-rotations: %v", err)
 		}
-		pb, err := client.NewProofBuilder(ctx, cp.Size, tileFetcher)
+	})
+
+	// Define a handler for /witness/status that reports which witnesses cosigned the latest
+	// checkpoint, and the outcome of the most recent request to each witness
+	http.HandleFunc("GET /witness/status", func(w http.ResponseWriter, r *http.Request) {
+		rawCp, err := readCheckpoint(r.Context())
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		inclusionProof, err := pb.InclusionProof(ctx, idx.Index)
+		cp, _, _, err := f_log.ParseCheckpoint(rawCp, v.Name(), v)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		// make sure the proof is valid
-		leafHash := rfc6962.DefaultHasher.HashLeaf([]byte(e.PURL))
-		if err := proof.VerifyInclusion(rfc6962.DefaultHasher, idx.Index, cp.Size, leafHash, inclusionProof, cp.Hash); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte(err.Error()))
-			return
-		}
 
-		resp := LogEntryResponse{
-			Index:          idx.Index,
-			InclusionProof: inclusionProof,
-			Checkpoint:     rawCp,
+		resp := WitnessStatusResponse{
+			Checkpoint:      rawCp,
+			TreeSize:        cp.Size,
+			RequestsTracked: requestsTracked,
+			Witnesses:       witnessStatuses(tracker, rawCp),
 		}
 
 		jResp, err := json.Marshal(resp)
@@ -203,16 +634,21 @@ func main() {
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
+		w.Header().Set("Cache-Control", "no-cache")
 		if _, err = w.Write(jResp); err != nil {
-			log.Printf("/add: %v", err)
+			log.Printf("/witness/status: %v", err)
 			return
 		}
 	})
 
-	// Proxy all GET requests to the filesystem as a lightweight file server.
-	fs := http.FileServer(http.Dir(*storageDir))
-	http.Handle("GET /checkpoint", addCacheHeaders("no-cache", fs))
-	http.Handle("GET /tile/", addCacheHeaders("max-age=31536000, immutable", fs))
+	if *storageBackend == "posix" {
+		// Proxy all GET requests to the filesystem as a lightweight file server.
+		fs := http.FileServer(http.Dir(*storageDir))
+		http.Handle("GET /checkpoint", addCacheHeaders("no-cache", fs))
+		http.Handle("GET /tile/", addCacheHeaders("max-age=31536000, immutable", fs))
+	} else {
+		handleReadAPI(http.DefaultServeMux, r)
+	}
 
 	address := fmt.Sprintf("%s:%d", *host, *port)
 	fmt.Printf("Server running at %s\n", address)
@@ -222,11 +658,18 @@ func main() {
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
 	srv := &http.Server{
-		Addr:    address,
-		Handler: http.DefaultServeMux,
+		Addr:      address,
+		Handler:   http.DefaultServeMux,
+		TLSConfig: tlsConfig,
 	}
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if *tlsCertFile != "" {
+			err = srv.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("error in ListenAndServe: %v", err)
 		}
 	}()
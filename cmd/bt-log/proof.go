@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/haydentherapper/bt-log/internal/index"
+	f_log "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/tessera/api/layout"
+	"github.com/transparency-dev/tessera/client"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// inclusionProof builds an inclusion proof for the leaf at the given index and verifies
+// it against the checkpoint's root hash
+func inclusionProof(ctx context.Context, pb *client.ProofBuilder, index uint64, leafHash []byte, cp *f_log.Checkpoint) ([][]byte, error) {
+	p, err := pb.InclusionProof(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, index, cp.Size, leafHash, p, cp.Hash); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// rootHash computes the root hash of the tree at the given size from the log's tiles
+func rootHash(ctx context.Context, f client.TileFetcherFunc, size uint64) ([]byte, error) {
+	nodes, err := client.FetchRangeNodes(ctx, size, f)
+	if err != nil {
+		return nil, err
+	}
+	rf := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+	cr, err := rf.NewRange(0, size, nodes)
+	if err != nil {
+		return nil, err
+	}
+	return cr.GetRootHash(nil)
+}
+
+// consistencyProof builds a consistency proof from the tree at oldSize to the checkpoint
+// and verifies it, computing the old root hash from the log's tiles
+func consistencyProof(ctx context.Context, pb *client.ProofBuilder, f client.TileFetcherFunc, oldSize uint64, cp *f_log.Checkpoint) ([][]byte, error) {
+	p, err := pb.ConsistencyProof(ctx, oldSize, cp.Size)
+	if err != nil {
+		return nil, err
+	}
+	// A proof from an empty tree is always empty
+	if oldSize == 0 {
+		return p, nil
+	}
+	oldRoot, err := rootHash(ctx, f, oldSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := proof.VerifyConsistency(rfc6962.DefaultHasher, oldSize, cp.Size, p, oldRoot, cp.Hash); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// proofAPI serves inclusion and consistency proofs against the log's checkpoints
+type proofAPI struct {
+	readCheckpoint  client.CheckpointFetcherFunc
+	readTile        client.TileFetcherFunc
+	readEntryBundle client.EntryBundleFetcherFunc
+	verifier        note.Verifier
+	// index finds entries by leaf hash. If nil, inclusion proofs can only be requested by index.
+	index *index.Store
+}
+
+// handleProofAPI registers the handlers that return proofs
+func handleProofAPI(mux *http.ServeMux, a *proofAPI) {
+	// Define a handler for /proof/inclusion that returns an inclusion proof for an entry, identified
+	// by either its index or its leaf hash, against the latest checkpoint or an optional older tree size
+	mux.HandleFunc("GET /proof/inclusion", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Has("index") == q.Has("leaf_hash") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("exactly one of index or leaf_hash must be set"))
+			return
+		}
+		// Finding a leaf hash without an index would mean reading every tile of the log
+		if q.Has("leaf_hash") && a.index == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("leaf_hash is not supported because the log is not indexed, use index"))
+			return
+		}
+
+		rawCp, err := a.readCheckpoint(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		cp, _, _, err := f_log.ParseCheckpoint(rawCp, a.verifier.Name(), a.verifier)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Default to the latest checkpoint. For an older tree size, compute its root hash
+		// from the tiles so the proof can be verified before returning it.
+		proofCp := cp
+		if q.Has("tree_size") {
+			treeSize, err := strconv.ParseUint(q.Get("tree_size"), 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("invalid tree_size: %v", err)))
+				return
+			}
+			if treeSize == 0 || treeSize > cp.Size {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("tree_size must be between 1 and the checkpoint size %d", cp.Size)))
+				return
+			}
+			if treeSize != cp.Size {
+				root, err := rootHash(r.Context(), a.readTile, treeSize)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				proofCp = &f_log.Checkpoint{Origin: cp.Origin, Size: treeSize, Hash: root}
+			}
+		}
+
+		var index uint64
+		var leafHash []byte
+		if q.Has("index") {
+			index, err = strconv.ParseUint(q.Get("index"), 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("invalid index: %v", err)))
+				return
+			}
+			if index >= proofCp.Size {
+				// Return 404 if the entry has not been published yet, so the client can retry
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(fmt.Sprintf("index %d not yet published, tree size is %d", index, proofCp.Size)))
+				return
+			}
+
+			// Fetch the entry to verify the proof before returning it
+			bundle, err := client.GetEntryBundle(r.Context(), a.readEntryBundle, index/layout.EntryBundleWidth, proofCp.Size)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			leafHash = rfc6962.DefaultHasher.HashLeaf(bundle.Entries[index%layout.EntryBundleWidth])
+		} else {
+			leafHash, err = hex.DecodeString(q.Get("leaf_hash"))
+			if err != nil || len(leafHash) != rfc6962.DefaultHasher.Size() {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("leaf_hash must be a hex-encoded SHA256 hash"))
+				return
+			}
+			found, err := a.index.FindLeafIndex(r.Context(), leafHash)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			// Entries are indexed once they're published, so an entry that isn't indexed may
+			// be published later
+			if found == nil || *found >= proofCp.Size {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(fmt.Sprintf("leaf hash not found in tree of size %d", proofCp.Size)))
+				return
+			}
+			index = *found
+		}
+
+		pb, err := client.NewProofBuilder(r.Context(), proofCp.Size, a.readTile)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		ip, err := inclusionProof(r.Context(), pb, index, leafHash, proofCp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		resp := InclusionProofResponse{
+			Index:          index,
+			TreeSize:       proofCp.Size,
+			InclusionProof: ip,
+		}
+		if proofCp == cp {
+			resp.Checkpoint = rawCp
+		}
+
+		jResp, err := json.Marshal(resp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err = w.Write(jResp); err != nil {
+			log.Printf("/proof/inclusion: %v", err)
+			return
+		}
+	})
+
+	// Define a handler for /proof/consistency that returns a consistency proof between two tree
+	// sizes, as newline-terminated base64-encoded hashes. This is the same format as the proof lines
+	// in a tlog-witness add-checkpoint request. If new is not set, the latest checkpoint size is used.
+	mux.HandleFunc("GET /proof/consistency", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		oldSize, err := strconv.ParseUint(q.Get("old"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("invalid old: %v", err)))
+			return
+		}
+
+		rawCp, err := a.readCheckpoint(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		cp, _, _, err := f_log.ParseCheckpoint(rawCp, a.verifier.Name(), a.verifier)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Default to the latest checkpoint. For an older tree size, compute its root hash
+		// from the tiles so the proof can be verified before returning it.
+		newCp := cp
+		if q.Has("new") {
+			newSize, err := strconv.ParseUint(q.Get("new"), 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("invalid new: %v", err)))
+				return
+			}
+			if newSize == 0 || newSize > cp.Size {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("new must be between 1 and the checkpoint size %d", cp.Size)))
+				return
+			}
+			if newSize != cp.Size {
+				root, err := rootHash(r.Context(), a.readTile, newSize)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				newCp = &f_log.Checkpoint{Origin: cp.Origin, Size: newSize, Hash: root}
+			}
+		}
+		if oldSize > newCp.Size {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("old must be less than or equal to new"))
+			return
+		}
+
+		pb, err := client.NewProofBuilder(r.Context(), newCp.Size, a.readTile)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		cProof, err := consistencyProof(r.Context(), pb, a.readTile, oldSize, newCp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		var b strings.Builder
+		for _, h := range cProof {
+			b.WriteString(base64.StdEncoding.EncodeToString(h))
+			b.WriteString("\n")
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(b.String())); err != nil {
+			log.Printf("/proof/consistency: %v", err)
+			return
+		}
+	})
+}
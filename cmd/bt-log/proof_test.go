@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	f_log "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// get sends a GET request to the log
+func (l *testLog) get(path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	l.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestInclusionProof(t *testing.T) {
+	l := newTestLog(t)
+
+	var added []LogEntryResponse
+	for i := range 3 {
+		var resp LogEntryResponse
+		if w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", fmt.Sprintf("1.0.%d", i), i)}, &resp); w.Code != http.StatusOK {
+			t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+		}
+		added = append(added, resp)
+	}
+	first := added[0]
+	leafHash := hex.EncodeToString(rfc6962.DefaultHasher.HashLeaf(first.Entry))
+
+	// Without an index, entries can only be found by index
+	if w := l.get("/proof/inclusion?leaf_hash=" + leafHash); w.Code != http.StatusBadRequest {
+		t.Errorf("/proof/inclusion by leaf hash without index status = %d, want 400: %s", w.Code, w.Body)
+	}
+	s := l.withIndex(t)
+	waitForIndex(t, s, added[2].Index+1)
+
+	// The first entry's checkpoint is an older tree size than the latest checkpoint
+	cp, _, _, err := f_log.ParseCheckpoint(first.Checkpoint, l.api.verifier.Name(), l.api.verifier)
+	if err != nil {
+		t.Fatalf("failed to parse checkpoint: %v", err)
+	}
+	for _, query := range []string{
+		fmt.Sprintf("index=%d", first.Index),
+		"leaf_hash=" + leafHash,
+		fmt.Sprintf("index=%d&tree_size=%d", first.Index, cp.Size),
+		fmt.Sprintf("leaf_hash=%s&tree_size=%d", leafHash, cp.Size),
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := l.get("/proof/inclusion?" + query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("/proof/inclusion status = %d: %s", w.Code, w.Body)
+			}
+			var resp InclusionProofResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if resp.Index != first.Index {
+				t.Errorf("/proof/inclusion index = %d, want %d", resp.Index, first.Index)
+			}
+			root := cp.Hash
+			if resp.Checkpoint != nil {
+				latest, _, _, err := f_log.ParseCheckpoint(resp.Checkpoint, l.api.verifier.Name(), l.api.verifier)
+				if err != nil {
+					t.Fatalf("failed to parse checkpoint: %v", err)
+				}
+				root = latest.Hash
+			} else if resp.TreeSize != cp.Size {
+				t.Fatalf("/proof/inclusion tree size = %d without checkpoint, want %d", resp.TreeSize, cp.Size)
+			}
+			if err := proof.VerifyInclusion(rfc6962.DefaultHasher, resp.Index, resp.TreeSize, rfc6962.DefaultHasher.HashLeaf(first.Entry), resp.InclusionProof, root); err != nil {
+				t.Errorf("inclusion proof doesn't verify: %v", err)
+			}
+		})
+	}
+
+	for query, want := range map[string]int{
+		"":                                      http.StatusBadRequest,
+		"index=0&leaf_hash=" + leafHash:         http.StatusBadRequest,
+		"leaf_hash=abcd":                        http.StatusBadRequest,
+		"index=1000":                            http.StatusNotFound,
+		"leaf_hash=" + strings.Repeat("00", 32): http.StatusNotFound,
+		"index=0&tree_size=1000":                http.StatusBadRequest,
+	} {
+		if w := l.get("/proof/inclusion?" + query); w.Code != want {
+			t.Errorf("/proof/inclusion?%s status = %d, want %d: %s", query, w.Code, want, w.Body)
+		}
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	l := newTestLog(t)
+
+	var added []LogEntryResponse
+	for i := range 3 {
+		var resp LogEntryResponse
+		if w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", fmt.Sprintf("1.0.%d", i), i)}, &resp); w.Code != http.StatusOK {
+			t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+		}
+		added = append(added, resp)
+	}
+	var cps []*f_log.Checkpoint
+	for _, a := range added {
+		cp, _, _, err := f_log.ParseCheckpoint(a.Checkpoint, l.api.verifier.Name(), l.api.verifier)
+		if err != nil {
+			t.Fatalf("failed to parse checkpoint: %v", err)
+		}
+		cps = append(cps, cp)
+	}
+	rawCp, err := l.readCheckpoint(t.Context())
+	if err != nil {
+		t.Fatalf("failed to read checkpoint: %v", err)
+	}
+	latest, _, _, err := f_log.ParseCheckpoint(rawCp, l.api.verifier.Name(), l.api.verifier)
+	if err != nil {
+		t.Fatalf("failed to parse checkpoint: %v", err)
+	}
+
+	for _, tc := range []struct {
+		query    string
+		old, new *f_log.Checkpoint
+	}{
+		{query: fmt.Sprintf("old=%d", cps[0].Size), old: cps[0], new: latest},
+		{query: fmt.Sprintf("old=%d&new=%d", cps[0].Size, cps[1].Size), old: cps[0], new: cps[1]},
+		{query: fmt.Sprintf("old=%d&new=%d", cps[1].Size, cps[1].Size), old: cps[1], new: cps[1]},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			w := l.get("/proof/consistency?" + tc.query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("/proof/consistency status = %d: %s", w.Code, w.Body)
+			}
+			var p [][]byte
+			for _, line := range strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n") {
+				if line == "" {
+					continue
+				}
+				h, err := base64.StdEncoding.DecodeString(line)
+				if err != nil {
+					t.Fatalf("failed to decode proof line %q: %v", line, err)
+				}
+				p = append(p, h)
+			}
+			if err := proof.VerifyConsistency(rfc6962.DefaultHasher, tc.old.Size, tc.new.Size, p, tc.old.Hash, tc.new.Hash); err != nil {
+				t.Errorf("consistency proof doesn't verify: %v", err)
+			}
+		})
+	}
+
+	for _, query := range []string{"", "old=x", fmt.Sprintf("old=%d&new=%d", cps[1].Size, cps[0].Size), "old=0&new=1000"} {
+		if w := l.get("/proof/consistency?" + query); w.Code != http.StatusBadRequest {
+			t.Errorf("/proof/consistency?%s status = %d, want 400: %s", query, w.Code, w.Body)
+		}
+	}
+}
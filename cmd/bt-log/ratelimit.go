@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/entry"
+)
+
+// submitterKey returns the key a submitter is rate limited by, which is its authenticated
+// identity, or its IP address if submitters aren't authenticated
+func submitterKey(r *http.Request, identity string) string {
+	if identity != "" {
+		return "identity:" + identity
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// quotaKey returns the package namespace an entry counts against for the daily quota, e.g.
+// pkg:npm/@scope or pkg:maven/org.example. For types without namespaces, such as pypi, each
+// package name is its own namespace.
+func quotaKey(e *entry.Entry) string {
+	if e.Namespace != "" {
+		return "pkg:" + e.Type + "/" + e.Namespace
+	}
+	return "pkg:" + e.Type + "/" + e.Name
+}
+
+// writeTooManyRequests writes a 429 with a Retry-After header in whole seconds
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = w.Write([]byte(msg))
+}
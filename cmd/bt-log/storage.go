@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/api/layout"
+	"github.com/transparency-dev/tessera/storage/aws"
+	"github.com/transparency-dev/tessera/storage/mysql"
+	"github.com/transparency-dev/tessera/storage/posix"
+)
+
+// mysqlSchema is the Tessera MySQL schema, copied from storage/mysql/schema.sql in the
+// Tessera module version in go.mod
+//
+//go:embed mysql_schema.sql
+var mysqlSchema string
+
+// storageConfig is the log storage set by the --storage-* and --s3-* flags
+type storageConfig struct {
+	backend string
+	// dir is the root directory for posix
+	dir string
+	// dsn is the log database for mysql, or the sequencing database for s3
+	dsn string
+	// bucket stores the log's tiles and checkpoint for s3
+	bucket string
+	// endpoint is an optional S3-compatible service for s3, e.g. MinIO
+	endpoint string
+}
+
+// newDriver returns the Tessera storage driver for the --storage-backend flag. The HTTP client
+// is used to request cosignatures from witnesses, except by the MySQL driver, which always
+// uses http.DefaultClient.
+func newDriver(ctx context.Context, c storageConfig, httpClient *http.Client) (tessera.Driver, error) {
+	switch c.backend {
+	case "posix":
+		return posix.New(ctx, posix.Config{Path: c.dir, HTTPClient: httpClient})
+	case "mysql":
+		db, err := sql.Open("mysql", c.dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %v", err)
+		}
+		if err := createMySQLSchema(ctx, db); err != nil {
+			return nil, err
+		}
+		return mysql.New(ctx, db)
+	case "s3":
+		cfg := aws.Config{Bucket: c.bucket, DSN: c.dsn, HTTPClient: httpClient}
+		if c.endpoint != "" {
+			// Credentials and region are still read from the environment, e.g. AWS_ACCESS_KEY_ID
+			sdkConfig, err := config.LoadDefaultConfig(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+			}
+			cfg.SDKConfig = &sdkConfig
+			cfg.S3Options = func(o *s3.Options) {
+				o.BaseEndpoint = &c.endpoint
+				o.UsePathStyle = true
+			}
+		}
+		return aws.New(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported --storage-backend: %s. Must be one of 'posix', 'mysql', 's3'", c.backend)
+	}
+}
+
+// createMySQLSchema creates the Tessera tables if they don't already exist. Each statement
+// is run separately, so the DSN doesn't need multiStatements=true.
+func createMySQLSchema(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range strings.Split(mysqlSchema, ";\n") {
+		var lines []string
+		for _, line := range strings.Split(stmt, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		stmt = strings.TrimSpace(strings.Join(lines, "\n"))
+		if stmt == "" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create schema: %v", err)
+		}
+	}
+	return nil
+}
+
+// handleReadAPI serves the tlog-tiles read endpoints from the log reader, for backends
+// that don't store the log as files that can be served directly
+func handleReadAPI(mux *http.ServeMux, r tessera.LogReader) {
+	mux.HandleFunc("GET /checkpoint", func(w http.ResponseWriter, req *http.Request) {
+		cp, err := r.ReadCheckpoint(req.Context())
+		writeResource(w, "/checkpoint", "no-cache", cp, err)
+	})
+	mux.HandleFunc("GET /tile/{level}/{index...}", func(w http.ResponseWriter, req *http.Request) {
+		level, index, p, err := layout.ParseTileLevelIndexPartial(req.PathValue("level"), req.PathValue("index"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		tile, err := r.ReadTile(req.Context(), level, index, p)
+		writeResource(w, "/tile", "max-age=31536000, immutable", tile, err)
+	})
+	mux.HandleFunc("GET /tile/entries/{index...}", func(w http.ResponseWriter, req *http.Request) {
+		index, p, err := layout.ParseTileIndexPartial(req.PathValue("index"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		bundle, err := r.ReadEntryBundle(req.Context(), index, p)
+		writeResource(w, "/tile/entries", "max-age=31536000, immutable", bundle, err)
+	})
+}
+
+// writeResource writes a resource read from the log with the given cache policy, or a 404
+// if it doesn't exist
+func writeResource(w http.ResponseWriter, endpoint, cacheControl string, data []byte, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%s: %v", endpoint, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("%s: %v", endpoint, err)
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/entry"
+	"github.com/haydentherapper/bt-log/internal/promise"
+	"github.com/haydentherapper/bt-log/internal/publisher"
+	"github.com/haydentherapper/bt-log/internal/purl"
+	"github.com/haydentherapper/bt-log/internal/ratelimit"
+	f_log "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/client"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// submitAPI validates submitted entries and adds them to the log, for /add, /add-async
+// and /add-batch
+type submitAPI struct {
+	purls   *purl.Verifier
+	auth    *submitterAuth
+	keyring *publisher.Keyring
+	limiter *ratelimit.Limiter
+	quota   *ratelimit.Quota
+	guard   *checksumGuard
+	dedup   *entryDedup
+
+	add      tessera.AddFn
+	await    *tessera.PublicationAwaiter
+	readTile client.TileFetcherFunc
+	verifier note.Verifier
+	// Promises are signed with the log's checkpoint signers
+	signer            note.Signer
+	additionalSigners []note.Signer
+	maxMergeDelay     time.Duration
+
+	// ctx outlives requests, so that entries are recorded once they're sequenced and
+	// clients that disconnect don't stop the wait for publication
+	ctx context.Context
+}
+
+// submitError is an entry that was rejected, with the status to respond with
+type submitError struct {
+	status int
+	// retryAfter is set for a 429
+	retryAfter time.Duration
+	// conflict is set for a 409
+	conflict *ConflictResponse
+	err      error
+}
+
+func (e *submitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *submitError) Unwrap() error {
+	return e.err
+}
+
+// writeSubmitError writes the status of a rejected entry, or the status of an error adding it
+func writeSubmitError(w http.ResponseWriter, err error) {
+	var se *submitError
+	if !errors.As(err, &se) {
+		writeAddError(w, err)
+		return
+	}
+	switch {
+	case se.conflict != nil:
+		writeConflictResp(w, se.conflict)
+	case se.status == http.StatusTooManyRequests:
+		writeTooManyRequests(w, se.retryAfter, se.err.Error())
+	default:
+		w.WriteHeader(se.status)
+		_, _ = w.Write([]byte(se.err.Error()))
+	}
+}
+
+// writeAddError writes a 503 if the log is pushing back because it's behind on integrating
+// or deduplicating entries, otherwise a 500
+func writeAddError(w http.ResponseWriter, err error) {
+	if errors.Is(err, tessera.ErrPushback) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("log is busy, retry later"))
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write([]byte(err.Error()))
+}
+
+// readRequest parses a JSON request body, writing an error and returning false if it can't
+func readRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return false
+	}
+	return true
+}
+
+// prepareEntry verifies a submitted entry and its optional publisher signature, and returns
+// the log entry and its encoding if the submitter may log it
+func (a *submitAPI) prepareEntry(identity string, e LogEntry) (*entry.Entry, []byte, error) {
+	if err := a.purls.Verify(e.PURL); err != nil {
+		return nil, nil, &submitError{status: http.StatusBadRequest, err: err}
+	}
+	keyID, err := publisherKeyID(a.keyring, e, identity)
+	if err != nil {
+		return nil, nil, &submitError{status: http.StatusBadRequest, err: err}
+	}
+	ent, data, err := newEntry(e.PURL, e.Filename, identity, keyID)
+	if err != nil {
+		return nil, nil, &submitError{status: http.StatusBadRequest, err: err}
+	}
+	if err := a.auth.authorize(identity, ent); err != nil {
+		return nil, nil, &submitError{status: http.StatusForbidden, err: err}
+	}
+	return ent, data, nil
+}
+
+// submit adds a prepared entry to the log, returning a future for its index. If an identical
+// entry has already been sequenced, the original index is returned. Only entries that are
+// added count against the quota, not resubmissions or conflicting entries. ctx is only used
+// to look up the entry; once its checksum is reserved, the entry is added with a.ctx, so a
+// client that disconnects doesn't stop it being sequenced.
+func (a *submitAPI) submit(ctx context.Context, ent *entry.Entry, data []byte) (tessera.IndexFuture, error) {
+	f, err := a.dedup.find(ctx, data)
+	if err != nil || f != nil {
+		return f, err
+	}
+	reserved, conflict, err := a.guard.reserve(ctx, ent)
+	if err != nil {
+		return nil, err
+	}
+	if conflict != nil {
+		return nil, &submitError{status: http.StatusConflict, conflict: conflict, err: errors.New(conflict.Error)}
+	}
+	if ok, retryAfter := a.quota.Allow(quotaKey(ent)); !ok {
+		// Don't hold the checksum for an entry that wasn't added
+		if reserved {
+			a.guard.release(a.ctx, ent)
+		}
+		return nil, &submitError{
+			status:     http.StatusTooManyRequests,
+			retryAfter: retryAfter,
+			err:        fmt.Errorf("daily quota exceeded for %s", quotaKey(ent)),
+		}
+	}
+
+	f = a.add(a.ctx, tessera.NewEntry(data))
+	// Futures are memoized, so the entry is recorded in the background while the caller waits
+	// on the same future
+	go func() {
+		a.guard.resolve(a.ctx, ent, f)
+		a.dedup.record(a.ctx, data, f)
+	}()
+	return f, nil
+}
+
+// handleSubmitAPI registers the handlers that add entries to the log
+func handleSubmitAPI(mux *http.ServeMux, a *submitAPI) {
+	// Define a handler for /add that accepts POST requests and adds the POST body to the log
+	mux.HandleFunc("POST /add", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.auth.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if ok, retryAfter := a.limiter.Allow(submitterKey(r, identity), 1); !ok {
+			writeTooManyRequests(w, retryAfter, "rate limit exceeded")
+			return
+		}
+		var e LogEntry
+		if !readRequest(w, r, &e) {
+			return
+		}
+
+		ent, data, err := a.prepareEntry(identity, e)
+		if err != nil {
+			writeSubmitError(w, err)
+			return
+		}
+		f, err := a.submit(r.Context(), ent, data)
+		if err != nil {
+			writeSubmitError(w, err)
+			return
+		}
+		idx, rawCp, err := a.await.Await(a.ctx, f)
+		if err != nil {
+			writeAddError(w, err)
+			return
+		}
+		cp, _, _, err := f_log.ParseCheckpoint(rawCp, a.verifier.Name(), a.verifier)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		pb, err := client.NewProofBuilder(a.ctx, cp.Size, a.readTile)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		// Build and verify the proof is valid
+		ip, err := inclusionProof(a.ctx, pb, idx.Index, rfc6962.DefaultHasher.HashLeaf(data), cp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		resp := LogEntryResponse{
+			Index:          idx.Index,
+			Entry:          data,
+			InclusionProof: ip,
+			Checkpoint:     rawCp,
+			Duplicate:      idx.IsDup,
+		}
+
+		jResp, err := json.Marshal(resp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err = w.Write(jResp); err != nil {
+			log.Printf("/add: %v", err)
+			return
+		}
+	})
+
+	// Define a handler for /add-async that accepts POST requests, adds the POST body to the log,
+	// and returns a signed promise to publish the entry once it has been sequenced, without waiting
+	// for a checkpoint. Clients fetch the inclusion proof later from /proof/inclusion.
+	mux.HandleFunc("POST /add-async", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.auth.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if ok, retryAfter := a.limiter.Allow(submitterKey(r, identity), 1); !ok {
+			writeTooManyRequests(w, retryAfter, "rate limit exceeded")
+			return
+		}
+		var e LogEntry
+		if !readRequest(w, r, &e) {
+			return
+		}
+
+		ent, data, err := a.prepareEntry(identity, e)
+		if err != nil {
+			writeSubmitError(w, err)
+			return
+		}
+		f, err := a.submit(r.Context(), ent, data)
+		if err != nil {
+			writeSubmitError(w, err)
+			return
+		}
+		// Wait only until the entry has been sequenced
+		idx, err := f()
+		if err != nil {
+			writeAddError(w, err)
+			return
+		}
+
+		p := promise.Promise{
+			Origin:   a.signer.Name(),
+			Index:    idx.Index,
+			LeafHash: rfc6962.DefaultHasher.HashLeaf(data),
+			Deadline: time.Now().Add(a.maxMergeDelay),
+		}
+		signedPromise, err := promise.Sign(p, a.signer, a.additionalSigners...)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		resp := InclusionPromiseResponse{
+			Index:     p.Index,
+			Entry:     data,
+			LeafHash:  p.LeafHash,
+			Deadline:  p.Deadline.Unix(),
+			Promise:   signedPromise,
+			Duplicate: idx.IsDup,
+		}
+
+		jResp, err := json.Marshal(resp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err = w.Write(jResp); err != nil {
+			log.Printf("/add-async: %v", err)
+			return
+		}
+	})
+
+	// Define a handler for /add-batch that accepts POST requests with a list of entries,
+	// adds each valid entry to the log, and returns per-entry results tied to one checkpoint
+	mux.HandleFunc("POST /add-batch", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.auth.authenticate(w, r)
+		if !ok {
+			return
+		}
+		var req BatchLogEntry
+		if !readRequest(w, r, &req) {
+			return
+		}
+		if len(req.PURLs) > 0 && len(req.Entries) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("only one of purls or entries must be set"))
+			return
+		}
+		entries := req.Entries
+		for _, p := range req.PURLs {
+			entries = append(entries, LogEntry{PURL: p})
+		}
+		if len(entries) == 0 || len(entries) > maxBatchSize {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("batch must contain between 1 and %d entries", maxBatchSize)))
+			return
+		}
+		// Each entry in the batch counts against the rate limit
+		if ok, retryAfter := a.limiter.Allow(submitterKey(r, identity), len(entries)); !ok {
+			writeTooManyRequests(w, retryAfter, "rate limit exceeded")
+			return
+		}
+
+		// Add all valid entries before waiting, so that they can be integrated together.
+		// Identical entries in the batch are only added once, sharing the first entry's index.
+		results := make([]BatchLogEntryResult, len(entries))
+		futures := make([]tessera.IndexFuture, len(entries))
+		data := make([][]byte, len(entries))
+		repeated := make([]bool, len(entries))
+		added := make(map[string]int)
+		for i, e := range entries {
+			ent, d, err := a.prepareEntry(identity, e)
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			if j, ok := added[string(d)]; ok {
+				data[i], futures[i], repeated[i] = d, futures[j], true
+				continue
+			}
+			f, err := a.submit(r.Context(), ent, d)
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			data[i], futures[i] = d, f
+			added[string(d)] = i
+		}
+
+		// Wait for all entries to be published, keeping the largest checkpoint seen
+		var rawCp []byte
+		var cp *f_log.Checkpoint
+		indices := make([]uint64, len(entries))
+		for i, f := range futures {
+			if f == nil {
+				continue
+			}
+			idx, c, err := a.await.Await(a.ctx, f)
+			if err != nil {
+				results[i].Error = err.Error()
+				futures[i] = nil
+				continue
+			}
+			indices[i] = idx.Index
+			results[i].Duplicate = idx.IsDup || repeated[i]
+			parsed, _, _, err := f_log.ParseCheckpoint(c, a.verifier.Name(), a.verifier)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			if cp == nil || parsed.Size > cp.Size {
+				cp, rawCp = parsed, c
+			}
+		}
+
+		// Build all inclusion proofs against the same checkpoint
+		if cp != nil {
+			pb, err := client.NewProofBuilder(a.ctx, cp.Size, a.readTile)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			for i, f := range futures {
+				if f == nil {
+					continue
+				}
+				ip, err := inclusionProof(a.ctx, pb, indices[i], rfc6962.DefaultHasher.HashLeaf(data[i]), cp)
+				if err != nil {
+					results[i].Error = err.Error()
+					results[i].Duplicate = false
+					continue
+				}
+				results[i].Index = &indices[i]
+				results[i].Entry = data[i]
+				results[i].InclusionProof = ip
+			}
+		}
+
+		resp := BatchLogEntryResponse{
+			Checkpoint: rawCp,
+			Results:    results,
+		}
+
+		jResp, err := json.Marshal(resp)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if _, err = w.Write(jResp); err != nil {
+			log.Printf("/add-batch: %v", err)
+			return
+		}
+	})
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/entry"
+	"github.com/haydentherapper/bt-log/internal/index"
+	"github.com/haydentherapper/bt-log/internal/promise"
+	"github.com/haydentherapper/bt-log/internal/publisher"
+	"github.com/haydentherapper/bt-log/internal/purl"
+	"github.com/haydentherapper/bt-log/internal/ratelimit"
+	f_log "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/client"
+	"github.com/transparency-dev/tessera/storage/posix"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// testLog is a log on local storage that publishes checkpoints quickly
+type testLog struct {
+	api             *submitAPI
+	proofs          *proofAPI
+	mux             *http.ServeMux
+	readCheckpoint  client.CheckpointFetcherFunc
+	readEntryBundle client.EntryBundleFetcherFunc
+}
+
+func newTestLog(t *testing.T) *testLog {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	skey, vkey, err := note.GenerateKey(rand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	verifier, err := note.NewVerifier(vkey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	driver, err := posix.New(ctx, posix.Config{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	appender, shutdown, r, err := tessera.NewAppender(ctx, driver, tessera.NewAppendOptions().
+		WithCheckpointSigner(signer).
+		WithCheckpointInterval(100*time.Millisecond).
+		WithBatching(maxBatchSize, 10*time.Millisecond).
+		WithAntispam(256, nil))
+	if err != nil {
+		t.Fatalf("failed to create appender: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = shutdown(ctx)
+		cancel()
+	})
+
+	l := &testLog{
+		api: &submitAPI{
+			purls:         purl.NewVerifier("pypi"),
+			add:           appender.Add,
+			await:         tessera.NewPublicationAwaiter(ctx, r.ReadCheckpoint, 10*time.Millisecond),
+			readTile:      r.ReadTile,
+			verifier:      verifier,
+			signer:        signer,
+			maxMergeDelay: time.Minute,
+			ctx:           ctx,
+		},
+		proofs: &proofAPI{
+			readCheckpoint:  r.ReadCheckpoint,
+			readTile:        r.ReadTile,
+			readEntryBundle: r.ReadEntryBundle,
+			verifier:        verifier,
+		},
+		mux:             http.NewServeMux(),
+		readCheckpoint:  r.ReadCheckpoint,
+		readEntryBundle: r.ReadEntryBundle,
+	}
+	handleSubmitAPI(l.mux, l.api)
+	handleProofAPI(l.mux, l.proofs)
+	return l
+}
+
+// withIndex indexes the log, deduplicating entries, rejecting conflicting checksums and
+// finding entries by leaf hash
+func (l *testLog) withIndex(t *testing.T) *index.Store {
+	t.Helper()
+	s, err := index.New(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("failed to open index: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	go followLog(l.api.ctx, s, l.readCheckpoint, l.readEntryBundle, l.api.verifier, 10*time.Millisecond)
+	l.api.guard = &checksumGuard{s: s}
+	l.api.dedup = &entryDedup{s: s}
+	l.proofs.index = s
+	return s
+}
+
+// withKeyring accepts submissions signed by the keys, each a line of a publisher keyring
+func (l *testLog) withKeyring(t *testing.T, keys ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keyring")
+	if err := os.WriteFile(path, []byte(strings.Join(keys, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write keyring: %v", err)
+	}
+	k, err := publisher.LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("failed to load keyring: %v", err)
+	}
+	l.api.keyring = k
+}
+
+// waitForIndex waits until the log has been indexed up to the size
+func waitForIndex(t *testing.T, s *index.Store, size uint64) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		if got, err := s.Size(context.Background()); err == nil && got >= size {
+			return
+		}
+	}
+	t.Fatalf("log wasn't indexed up to size %d", size)
+}
+
+// post sends a JSON request to the log, decoding a 200 response into resp
+func (l *testLog) post(t *testing.T, path string, req, resp any) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	l.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(b))))
+	if w.Code == http.StatusOK && resp != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+			t.Fatalf("failed to unmarshal %s response: %v", path, err)
+		}
+	}
+	return w
+}
+
+// testPURL returns a valid pypi pURL for the package version with a checksum derived from n
+func testPURL(name, version string, n int) string {
+	return fmt.Sprintf("pkg:pypi/%s@%s?checksum=sha256:%064x", name, version, n)
+}
+
+// verifyInclusion verifies the entry is included in the checkpoint at the index
+func (l *testLog) verifyInclusion(t *testing.T, data, rawCp []byte, index uint64, ip [][]byte) {
+	t.Helper()
+	cp, _, _, err := f_log.ParseCheckpoint(rawCp, l.api.verifier.Name(), l.api.verifier)
+	if err != nil {
+		t.Fatalf("failed to verify checkpoint: %v", err)
+	}
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, index, cp.Size, rfc6962.DefaultHasher.HashLeaf(data), ip, cp.Hash); err != nil {
+		t.Errorf("inclusion proof for index %d doesn't verify: %v", index, err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	l := newTestLog(t)
+
+	p := testPURL("pkgname", "1.2.3", 1)
+	var first LogEntryResponse
+	if w := l.post(t, "/add", LogEntry{PURL: p, Filename: "pkgname-1.2.3.tar.gz"}, &first); w.Code != http.StatusOK {
+		t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+	}
+	l.verifyInclusion(t, first.Entry, first.Checkpoint, first.Index, first.InclusionProof)
+	var e entry.Entry
+	if err := e.Unmarshal(first.Entry); err != nil || e.PURL() != strings.Replace(p, "sha256:", "sha256%3A", 1) || e.Filename != "pkgname-1.2.3.tar.gz" {
+		t.Errorf("/add entry = %+v, %v, want entry for %s", e, err, p)
+	}
+
+	// Resubmitting returns the original index
+	var dup LogEntryResponse
+	if w := l.post(t, "/add", LogEntry{PURL: p, Filename: "pkgname-1.2.3.tar.gz"}, &dup); w.Code != http.StatusOK {
+		t.Fatalf("/add resubmission status = %d: %s", w.Code, w.Body)
+	}
+	if !dup.Duplicate || dup.Index != first.Index {
+		t.Errorf("/add resubmission = index %d, duplicate %t, want duplicate of index %d", dup.Index, dup.Duplicate, first.Index)
+	}
+
+	for name, tc := range map[string]struct {
+		body string
+		want int
+	}{
+		"invalid JSON":           {body: "{", want: http.StatusBadRequest},
+		"invalid pURL":           {body: `{"purl": "pkg:npm/pkgname@1.2.3"}`, want: http.StatusBadRequest},
+		"missing checksum":       {body: `{"purl": "pkg:pypi/pkgname@1.2.3"}`, want: http.StatusBadRequest},
+		"signed without keyring": {body: fmt.Sprintf(`{"purl": %q, "signature": "AAAA"}`, p), want: http.StatusBadRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			l.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(tc.body)))
+			if w.Code != tc.want {
+				t.Errorf("/add status = %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestAddSigned(t *testing.T) {
+	l := newTestLog(t)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	l.withKeyring(t, "alice "+base64.StdEncoding.EncodeToString(der))
+
+	p := testPURL("pkgname", "1.2.3", 1)
+	msg, err := publisher.Submission{PURL: p, Filename: "pkgname-1.2.3.tar.gz"}.Message()
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	sig := ed25519.Sign(priv, msg)
+
+	// The signature covers the filename, so it can't be reused for another file
+	if w := l.post(t, "/add", LogEntry{PURL: p, Filename: "pkgname-1.2.3-py3-none-any.whl", Signature: sig}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("/add with signature for another filename status = %d, want 400: %s", w.Code, w.Body)
+	}
+	// Signatures over only the pURL aren't accepted
+	if w := l.post(t, "/add", LogEntry{PURL: p, Signature: ed25519.Sign(priv, []byte(p))}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("/add with signature over the pURL status = %d, want 400: %s", w.Code, w.Body)
+	}
+
+	var resp LogEntryResponse
+	if w := l.post(t, "/add", LogEntry{PURL: p, Filename: "pkgname-1.2.3.tar.gz", Signature: sig}, &resp); w.Code != http.StatusOK {
+		t.Fatalf("/add with signature status = %d: %s", w.Code, w.Body)
+	}
+	var e entry.Entry
+	if err := e.Unmarshal(resp.Entry); err != nil || e.PublisherKeyID != "alice" {
+		t.Errorf("/add entry = %+v, %v, want entry signed by alice", e, err)
+	}
+}
+
+func TestAddAsync(t *testing.T) {
+	l := newTestLog(t)
+
+	p := testPURL("pkgname", "1.2.3", 1)
+	var resp InclusionPromiseResponse
+	if w := l.post(t, "/add-async", LogEntry{PURL: p}, &resp); w.Code != http.StatusOK {
+		t.Fatalf("/add-async status = %d: %s", w.Code, w.Body)
+	}
+	pr, err := promise.Verify(resp.Promise, l.api.verifier)
+	if err != nil {
+		t.Fatalf("failed to verify promise: %v", err)
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(resp.Entry)
+	if pr.Index != resp.Index || string(pr.LeafHash) != string(leafHash) || pr.Deadline.Unix() != resp.Deadline {
+		t.Errorf("promise = %+v, want promise for response %+v", pr, resp)
+	}
+
+	// The promised entry is published at the promised index
+	var added LogEntryResponse
+	if w := l.post(t, "/add", LogEntry{PURL: p}, &added); w.Code != http.StatusOK {
+		t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+	}
+	if added.Index != resp.Index || !added.Duplicate {
+		t.Errorf("/add after /add-async = index %d, duplicate %t, want duplicate of index %d", added.Index, added.Duplicate, resp.Index)
+	}
+	l.verifyInclusion(t, added.Entry, added.Checkpoint, added.Index, added.InclusionProof)
+}
+
+func TestAddQuota(t *testing.T) {
+	l := newTestLog(t)
+	s := l.withIndex(t)
+	l.api.quota = ratelimit.NewQuota(2)
+
+	var resp LogEntryResponse
+	if w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", "1.0.0", 1)}, &resp); w.Code != http.StatusOK {
+		t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+	}
+	waitForIndex(t, s, resp.Index+1)
+
+	// Neither conflicting entries nor resubmissions count against the quota
+	for range 3 {
+		var conflict ConflictResponse
+		w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", "1.0.0", 2)}, nil)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("/add with conflicting checksum status = %d, want 409: %s", w.Code, w.Body)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil || conflict.Index == nil || *conflict.Index != resp.Index {
+			t.Errorf("/add with conflicting checksum = %+v, %v, want conflict with index %d", conflict, err, resp.Index)
+		}
+		if w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", "1.0.0", 1)}, nil); w.Code != http.StatusOK {
+			t.Fatalf("/add resubmission status = %d: %s", w.Code, w.Body)
+		}
+	}
+	if w := l.post(t, "/add-async", LogEntry{PURL: testPURL("pkgname", "2.0.0", 3)}, nil); w.Code != http.StatusOK {
+		t.Fatalf("/add-async status = %d: %s", w.Code, w.Body)
+	}
+
+	// Once the quota is used, entries are rejected without reserving their checksum
+	w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", "3.0.0", 4)}, nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("/add over quota status = %d, want 429 with Retry-After: %s", w.Code, w.Body)
+	}
+	if p, err := s.Reserve(context.Background(), "pkg:pypi/pkgname@3.0.0", "sha256:05"); err != nil || !p.Reserved {
+		t.Errorf("Reserve() after quota exceeded = %+v, %v, want new reservation", p, err)
+	}
+}
+
+func TestAddNoSharedChecksum(t *testing.T) {
+	l := newTestLog(t)
+	l.withIndex(t)
+	v, err := purl.NewVerifier("pypi").WithChecksumPolicy(purl.ChecksumPolicy{Algorithms: []string{"sha256", "sha512"}})
+	if err != nil {
+		t.Fatalf("WithChecksumPolicy() error = %v", err)
+	}
+	l.api.purls = v
+	sha256 := "sha256:" + strings.Repeat("ab", 32)
+	sha512 := "sha512:" + strings.Repeat("cd", 64)
+
+	if w := l.post(t, "/add", LogEntry{PURL: "pkg:pypi/pkgname@1.0.0?checksum=" + sha256}, nil); w.Code != http.StatusOK {
+		t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+	}
+	// A checksum with no algorithm in common can't be compared, so it isn't a conflict
+	w := l.post(t, "/add", LogEntry{PURL: "pkg:pypi/pkgname@1.0.0?checksum=" + sha512}, nil)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "shares no algorithm") {
+		t.Errorf("/add with no shared checksum algorithm = %d %s, want 422", w.Code, w.Body)
+	}
+	// Listing the recorded algorithm alongside makes the checksums comparable
+	if w := l.post(t, "/add", LogEntry{PURL: "pkg:pypi/pkgname@1.0.0?checksum=" + sha512 + "," + sha256}, nil); w.Code != http.StatusOK {
+		t.Errorf("/add with shared checksum algorithm status = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestAddBatch(t *testing.T) {
+	l := newTestLog(t)
+	s := l.withIndex(t)
+	l.api.quota = ratelimit.NewQuota(2)
+
+	// Publishers can sign entries in a batch
+	skey, vkey, err := note.GenerateKey(rand.Reader, "alice")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	l.withKeyring(t, vkey)
+	signed := testPURL("other", "1.0.0", 5)
+	msg, err := publisher.Submission{PURL: signed}.Message()
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	signedNote, err := note.Sign(&note.Note{Text: string(msg)}, signer)
+	if err != nil {
+		t.Fatalf("failed to sign note: %v", err)
+	}
+
+	var logged LogEntryResponse
+	if w := l.post(t, "/add", LogEntry{PURL: testPURL("pkgname", "1.0.0", 1)}, &logged); w.Code != http.StatusOK {
+		t.Fatalf("/add status = %d: %s", w.Code, w.Body)
+	}
+	waitForIndex(t, s, logged.Index+1)
+
+	entries := []LogEntry{
+		{PURL: testPURL("pkgname", "2.0.0", 2), Filename: "pkgname-2.0.0.tar.gz"},
+		{PURL: testPURL("pkgname", "2.0.0", 2), Filename: "pkgname-2.0.0.tar.gz"},
+		{PURL: "pkg:pypi/pkgname"},
+		{PURL: testPURL("pkgname", "1.0.0", 3)},
+		{PURL: testPURL("pkgname", "1.0.0", 1)},
+		{PURL: signed, Note: string(signedNote)},
+		{PURL: testPURL("pkgname", "3.0.0", 4)},
+		{PURL: testPURL("other", "2.0.0", 6), Note: string(signedNote)},
+	}
+	var resp BatchLogEntryResponse
+	if w := l.post(t, "/add-batch", BatchLogEntry{Entries: entries}, &resp); w.Code != http.StatusOK {
+		t.Fatalf("/add-batch status = %d: %s", w.Code, w.Body)
+	}
+	if len(resp.Results) != len(entries) {
+		t.Fatalf("/add-batch returned %d results, want %d", len(resp.Results), len(entries))
+	}
+	for i, r := range resp.Results {
+		if r.Error != "" {
+			if r.Index != nil || r.Entry != nil || r.InclusionProof != nil || r.Duplicate {
+				t.Errorf("result %d = %+v, want only an error", i, r)
+			}
+			continue
+		}
+		if r.Index == nil {
+			t.Errorf("result %d = %+v, want index or error", i, r)
+			continue
+		}
+		l.verifyInclusion(t, r.Entry, resp.Checkpoint, *r.Index, r.InclusionProof)
+	}
+
+	results := resp.Results
+	if r := results[0]; r.Index == nil || r.Duplicate {
+		t.Errorf("result for new entry = %+v, want new index", r)
+	}
+	// Repeated entries in a batch are only logged once
+	if r := results[1]; r.Index == nil || results[0].Index == nil || *r.Index != *results[0].Index || !r.Duplicate {
+		t.Errorf("result for repeated entry = %+v, want duplicate of %+v", r, results[0])
+	}
+	if r := results[2]; r.Error == "" {
+		t.Errorf("result for invalid pURL = %+v, want error", r)
+	}
+	if r := results[3]; !strings.Contains(r.Error, "already logged with checksum") {
+		t.Errorf("result for conflicting checksum = %+v, want conflict error", r)
+	}
+	if r := results[4]; r.Index == nil || *r.Index != logged.Index || !r.Duplicate {
+		t.Errorf("result for resubmitted entry = %+v, want duplicate of index %d", r, logged.Index)
+	}
+	var e entry.Entry
+	if r := results[5]; r.Index == nil || e.Unmarshal(r.Entry) != nil || e.PublisherKeyID != "alice" {
+		t.Errorf("result for signed entry = %+v, entry %+v, want entry signed by alice", r, e)
+	}
+	// Only entries that were added count against the quota
+	if r := results[6]; !strings.Contains(r.Error, "daily quota exceeded") {
+		t.Errorf("result for entry over quota = %+v, want quota error", r)
+	}
+	if r := results[7]; r.Error == "" {
+		t.Errorf("result for entry with another entry's signature = %+v, want error", r)
+	}
+
+	for name, body := range map[string]string{
+		"empty":             `{"purls": []}`,
+		"purls and entries": fmt.Sprintf(`{"purls": [%q], "entries": [{"purl": %q}]}`, signed, signed),
+		"too many entries":  `{"purls": [` + strings.Repeat(`"pkg:pypi/a@1",`, maxBatchSize) + `"pkg:pypi/a@1"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			l.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/add-batch", strings.NewReader(body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("/add-batch status = %d, want 400: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestAddClientDisconnected(t *testing.T) {
+	l := newTestLog(t)
+
+	// Entries are added to the log even if the client disconnects before they're sequenced,
+	// which would cancel storage that checks the context
+	add := l.api.add
+	l.api.add = func(ctx context.Context, e *tessera.Entry) tessera.IndexFuture {
+		if err := ctx.Err(); err != nil {
+			return func() (tessera.Index, error) { return tessera.Index{}, err }
+		}
+		return add(ctx, e)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ent, data, err := l.api.prepareEntry("", LogEntry{PURL: testPURL("pkgname", "1.2.3", 1)})
+	if err != nil {
+		t.Fatalf("prepareEntry() error = %v", err)
+	}
+	f, err := l.api.submit(ctx, ent, data)
+	if err != nil {
+		t.Fatalf("submit() error = %v", err)
+	}
+	if _, _, err := l.api.await.Await(l.api.ctx, f); err != nil {
+		t.Errorf("entry submitted by disconnected client wasn't published: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/witness"
+)
+
+// WitnessStatusResponse is returned by /witness/status. Checkpoint is the latest published
+// checkpoint, including its cosignatures. RequestsTracked is false if the storage backend
+// doesn't record the outcome of cosigning requests, in which case only cosignatures are reported.
+type WitnessStatusResponse struct {
+	Checkpoint      []byte          `json:"checkpoint"`
+	TreeSize        uint64          `json:"treeSize"`
+	RequestsTracked bool            `json:"requestsTracked"`
+	Witnesses       []WitnessStatus `json:"witnesses"`
+}
+
+// WitnessStatus reports whether a witness cosigned the latest checkpoint, and the outcome of
+// its most recent cosigning requests since the log started. Times are unix seconds, and are
+// omitted if there has been no such cosignature or request.
+type WitnessStatus struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Cosigned    bool   `json:"cosigned"`
+	CosignedAt  int64  `json:"cosignedAt,omitempty"`
+	LastSuccess int64  `json:"lastSuccess,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	LastErrorAt int64  `json:"lastErrorAt,omitempty"`
+}
+
+// witnessStatuses returns the status of each witness for the checkpoint, or no statuses if
+// the log isn't witnessed
+func witnessStatuses(t *witness.Tracker, cp []byte) []WitnessStatus {
+	statuses := []WitnessStatus{}
+	if t == nil {
+		return statuses
+	}
+	for _, s := range t.Status(cp) {
+		statuses = append(statuses, WitnessStatus{
+			Name:        s.Name,
+			URL:         s.URL,
+			Cosigned:    s.Cosigned,
+			CosignedAt:  unixSeconds(s.CosignedAt),
+			LastSuccess: unixSeconds(s.LastSuccess),
+			LastError:   s.LastError,
+			LastErrorAt: unixSeconds(s.LastErrorAt),
+		})
+	}
+	return statuses
+}
+
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
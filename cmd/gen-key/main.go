@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 
+	"github.com/haydentherapper/bt-log/internal/key"
+	"github.com/haydentherapper/bt-log/internal/rotation"
 	"golang.org/x/mod/sumdb/note"
 )
 
@@ -13,6 +15,8 @@ var (
 	origin      = flag.String("origin", "", "Origin of checkpoint, e.g. example.com/log")
 	privKeyPath = flag.String("private-key-path", "private.key", "Output path for private key")
 	pubKeyPath  = flag.String("public-key-path", "public.key", "Output path for public key")
+	rotateFrom  = flag.String("rotate-from", "", "Optional path for the private key being replaced, to endorse the new key with a key rotation statement")
+	rotPath     = flag.String("rotation-statement-path", "key-rotation.txt", "Output path for key rotation statement, with --rotate-from")
 )
 
 func fileExists(filename string) bool {
@@ -31,11 +35,22 @@ func main() {
 	if fileExists(*pubKeyPath) {
 		log.Fatalf("--public-key-path file must not exist")
 	}
+	if *rotateFrom != "" && fileExists(*rotPath) {
+		log.Fatalf("--rotation-statement-path file must not exist")
+	}
 
 	privKey, pubKey, err := note.GenerateKey(rand.Reader, *origin)
 	if err != nil {
 		log.Fatalf("error generating key: %v", err)
 	}
+	// Sign the rotation statement before writing any files, so an invalid old key writes nothing
+	var statement []byte
+	if *rotateFrom != "" {
+		statement, err = rotationStatement(*rotateFrom, privKey, pubKey)
+		if err != nil {
+			log.Fatalf("error creating key rotation statement: %v", err)
+		}
+	}
 
 	if err := os.WriteFile(*privKeyPath, []byte(privKey), 0o644); err != nil {
 		log.Fatalf("error writing private key: %v", err)
@@ -43,4 +58,30 @@ func main() {
 	if err := os.WriteFile(*pubKeyPath, []byte(pubKey), 0o644); err != nil {
 		log.Fatalf("error writing public key: %v", err)
 	}
+	if statement != nil {
+		if err := os.WriteFile(*rotPath, statement, 0o644); err != nil {
+			log.Fatalf("error writing key rotation statement: %v", err)
+		}
+	}
+}
+
+// rotationStatement returns a statement endorsing the new key, signed by the old and new keys
+func rotationStatement(oldPrivKeyPath, privKey, pubKey string) ([]byte, error) {
+	oldPrivKey, err := os.ReadFile(oldPrivKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	oldSigner, err := note.NewSigner(string(oldPrivKey))
+	if err != nil {
+		return nil, err
+	}
+	oldPubKey, err := key.VerifierKey(string(oldPrivKey))
+	if err != nil {
+		return nil, err
+	}
+	newSigner, err := note.NewSigner(privKey)
+	if err != nil {
+		return nil, err
+	}
+	return rotation.Sign(rotation.Statement{Origin: *origin, OldKey: oldPubKey, NewKey: pubKey}, oldSigner, newSigner)
 }
@@ -1,20 +1,14 @@
 package main
 
 import (
-	"crypto/sha256"
-	"database/sql"
-	"encoding/base64"
+	"context"
+	"errors"
 	"flag"
-	"fmt"
 	"log"
 	"os"
 
-	"github.com/haydentherapper/bt-log/internal/db/postgres"
+	"github.com/haydentherapper/bt-log/internal/db"
 	"golang.org/x/mod/sumdb/note"
-
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "modernc.org/sqlite"
 )
 
 var (
@@ -37,52 +31,11 @@ func main() {
 		log.Fatalf("--public-key required to add log key to witness")
 	}
 
-	var driverName, dsn string
-	rebind := func(s string) string { return s } // Default is no-op for mysql and sqlite
-
-	switch *dbType {
-	case "sqlite":
-		driverName = "sqlite"
-		if *dbDSN != "" {
-			dsn = *dbDSN
-		} else {
-			dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=1000", *dbPath)
-		}
-	case "mysql":
-		driverName = "mysql"
-		if *dbDSN == "" {
-			log.Fatalf("--db-dsn must be set for --db-type=mysql")
-		}
-		dsn = *dbDSN
-	case "postgres":
-		driverName = "pgx"
-		if *dbDSN == "" {
-			log.Fatalf("--db-dsn must be set for --db-type=postgres")
-		}
-		dsn = *dbDSN
-		rebind = postgres.Rebind
-	default:
-		log.Fatalf("unsupported --db-type: %s. Must be one of 'sqlite', 'mysql', 'postgres'", *dbType)
-	}
-
-	db, err := sql.Open(driverName, dsn)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-
-	// Create the table (if it doesn't already exist)
-	_, err = db.Exec(`
-			CREATE TABLE IF NOT EXISTS tlog (
-					origin VARCHAR(255) PRIMARY KEY,
-					public_key TEXT NOT NULL, -- note verifier format
-					tree_size INTEGER NOT NULL,
-					tree_hash TEXT NOT NULL -- base64-encoded
-			)
-	`)
+	store, err := db.Open(*dbType, *dbPath, *dbDSN)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer store.Close()
 
 	pubKey, err := os.ReadFile(*pubKeyFile)
 	if err != nil {
@@ -94,30 +47,9 @@ func main() {
 		log.Fatalf("failed to read verifier %s: %v", *pubKeyFile, err)
 	}
 
-	// Check if the origin (primary key) already exists
-	var count int
-	query := rebind("SELECT COUNT(*) FROM tlog WHERE origin = ?")
-	err = db.QueryRow(query, v.Name()).Scan(&count)
-	if err != nil {
-		log.Fatalf("failed to check for existing origin: %v", err)
-	}
-	if count > 0 {
+	if err := store.AddLog(context.Background(), v.Name(), string(pubKey)); errors.Is(err, db.ErrExists) {
 		log.Printf("Origin '%s' already exists. Skipping.", v.Name())
-		return
-	}
-
-	// root hash for empty merkle tree
-	emptyRoot := sha256.Sum256([]byte{})
-
-	insertQuery := rebind("INSERT INTO tlog (origin, public_key, tree_size, tree_hash) VALUES (?, ?, ?, ?)")
-	r, err := db.Exec(insertQuery,
-		v.Name(), string(pubKey), 0, base64.StdEncoding.EncodeToString(emptyRoot[:]))
-	if err != nil {
-		log.Fatal(err)
-	}
-	if c, err := r.RowsAffected(); err != nil {
+	} else if err != nil {
 		log.Fatal(err)
-	} else if c != 1 {
-		log.Fatalf("expected one new row, inserted %d new rows", c)
 	}
 }
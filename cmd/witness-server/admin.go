@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/haydentherapper/bt-log/internal/auth"
+	"github.com/haydentherapper/bt-log/internal/db"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// TrackedLog is a log the witness verifies checkpoints for, as returned by the admin API.
+// TreeSize and TreeHash are the last size and root hash the witness verified.
+type TrackedLog struct {
+	Origin    string `json:"origin"`
+	PublicKey string `json:"publicKey"`
+	TreeSize  uint64 `json:"treeSize"`
+	TreeHash  []byte `json:"treeHash"`
+}
+
+// LogKeyRequest is the request body to add a log, or replace a log's key. The log's origin
+// is the key name.
+type LogKeyRequest struct {
+	PublicKey string `json:"publicKey"`
+}
+
+// adminAPI manages the logs the witness tracks
+type adminAPI struct {
+	store db.WitnessStore
+	authn auth.Authenticator
+}
+
+// authenticate returns the identity of the administrator. If the administrator can't be
+// authenticated, a 401 is written and false is returned.
+func (a *adminAPI) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	identity, err := a.authn.Authenticate(r)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			log.Printf("failed to authenticate administrator: %v", err)
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("administrator must be authenticated"))
+		return "", false
+	}
+	return identity, true
+}
+
+// isLoopback reports whether the host only accepts connections from the local machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// readLogKey parses a LogKeyRequest, writing a 400 and returning false if it's invalid
+func readLogKey(w http.ResponseWriter, r *http.Request) (string, note.Verifier, bool) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return "", nil, false
+	}
+	var req LogKeyRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return "", nil, false
+	}
+	publicKey := strings.TrimSpace(req.PublicKey)
+	v, err := note.NewVerifier(publicKey)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return "", nil, false
+	}
+	return publicKey, v, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, endpoint string, v any) {
+	jResp, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(jResp); err != nil {
+		log.Printf("%s: %v", endpoint, err)
+	}
+}
+
+// newTrackedLog returns the admin API view of a stored log
+func newTrackedLog(l *db.Log) TrackedLog {
+	return TrackedLog{Origin: l.Origin, PublicKey: strings.TrimSpace(l.PublicKey), TreeSize: l.TreeSize, TreeHash: l.TreeHash}
+}
+
+// handleAdminAPI registers handlers to list, add and remove tracked logs, and replace their keys
+func handleAdminAPI(mux *http.ServeMux, a *adminAPI) {
+	mux.HandleFunc("GET /admin/logs", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := a.authenticate(w, r); !ok {
+			return
+		}
+		logs, err := a.store.ListLogs(r.Context())
+		if err != nil {
+			log.Printf("error listing logs: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		tracked := []TrackedLog{}
+		for _, l := range logs {
+			tracked = append(tracked, newTrackedLog(&l))
+		}
+		writeJSON(w, http.StatusOK, "/admin/logs", tracked)
+	})
+
+	mux.HandleFunc("GET /admin/logs/{origin...}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := a.authenticate(w, r); !ok {
+			return
+		}
+		l, err := a.store.GetLog(r.Context(), r.PathValue("origin"))
+		if errors.Is(err, db.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("unknown origin"))
+			return
+		} else if err != nil {
+			log.Printf("error reading log: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, "/admin/logs", newTrackedLog(l))
+	})
+
+	// Add a log, whose origin is its key name. The witness will verify the log's checkpoints
+	// starting from an empty tree.
+	mux.HandleFunc("POST /admin/logs", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+		publicKey, v, ok := readLogKey(w, r)
+		if !ok {
+			return
+		}
+		if err := a.store.AddLog(r.Context(), v.Name(), publicKey); errors.Is(err, db.ErrExists) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("origin already tracked"))
+			return
+		} else if err != nil {
+			log.Printf("error adding log: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		l, err := a.store.GetLog(r.Context(), v.Name())
+		if err != nil {
+			log.Printf("error reading log: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Printf("%s added log %s with key %s\n", identity, v.Name(), publicKey)
+		writeJSON(w, http.StatusCreated, "/admin/logs", newTrackedLog(l))
+	})
+
+	// Replace a log's key, keeping its last verified size and hash. The new key must have the
+	// same name as the origin.
+	mux.HandleFunc("PUT /admin/logs/{origin...}", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+		origin := r.PathValue("origin")
+		publicKey, v, ok := readLogKey(w, r)
+		if !ok {
+			return
+		}
+		if v.Name() != origin {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("key name must match the origin"))
+			return
+		}
+		if err := a.store.SetPublicKey(r.Context(), origin, publicKey); errors.Is(err, db.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("unknown origin"))
+			return
+		} else if err != nil {
+			log.Printf("error replacing log key: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		l, err := a.store.GetLog(r.Context(), origin)
+		if err != nil {
+			log.Printf("error reading log: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Printf("%s replaced key for log %s with %s\n", identity, origin, publicKey)
+		writeJSON(w, http.StatusOK, "/admin/logs", newTrackedLog(l))
+	})
+
+	mux.HandleFunc("DELETE /admin/logs/{origin...}", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+		origin := r.PathValue("origin")
+		if err := a.store.DeleteLog(r.Context(), origin); errors.Is(err, db.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("unknown origin"))
+			return
+		} else if err != nil {
+			log.Printf("error removing log: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Printf("%s removed log %s\n", identity, origin)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
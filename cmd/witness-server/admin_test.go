@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/haydentherapper/bt-log/internal/auth"
+	"github.com/haydentherapper/bt-log/internal/db"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const adminToken = "admin-token"
+
+// newTestAdminServer serves the admin API for a new witness database, accepting adminToken
+func newTestAdminServer(t *testing.T) (*httptest.Server, db.WitnessStore) {
+	t.Helper()
+	store, err := db.Open("sqlite", filepath.Join(t.TempDir(), "witness.db"), "")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	hash := sha256.Sum256([]byte(adminToken))
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte("alice "+hex.EncodeToString(hash[:])+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	authn, err := auth.NewTokenAuthenticator(path)
+	if err != nil {
+		t.Fatalf("failed to load tokens: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	handleAdminAPI(mux, &adminAPI{store: store, authn: authn})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+// testVerifierKey returns a new note verifier key named origin
+func testVerifierKey(t *testing.T, origin string) string {
+	t.Helper()
+	_, vkey, err := note.GenerateKey(rand.Reader, origin)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return vkey
+}
+
+// adminRequest sends a request to the admin API with the token, returning the status and body
+func adminRequest(t *testing.T, srv *httptest.Server, method, path, token, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func logKeyBody(publicKey string) string {
+	b, _ := json.Marshal(LogKeyRequest{PublicKey: publicKey})
+	return string(b)
+}
+
+func TestAdminAPIAuthentication(t *testing.T) {
+	srv, _ := newTestAdminServer(t)
+
+	for name, token := range map[string]string{
+		"no token":      "",
+		"unknown token": "not-the-admin-token",
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, r := range []struct{ method, path, body string }{
+				{http.MethodGet, "/admin/logs", ""},
+				{http.MethodGet, "/admin/logs/a.example", ""},
+				{http.MethodPost, "/admin/logs", logKeyBody(testVerifierKey(t, "a.example"))},
+				{http.MethodPut, "/admin/logs/a.example", logKeyBody(testVerifierKey(t, "a.example"))},
+				{http.MethodDelete, "/admin/logs/a.example", ""},
+			} {
+				if status, body := adminRequest(t, srv, r.method, r.path, token, r.body); status != http.StatusUnauthorized {
+					t.Errorf("%s %s status = %d, want 401: %s", r.method, r.path, status, body)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	srv, store := newTestAdminServer(t)
+	origin := "binarytransparency.log/example"
+	key := testVerifierKey(t, origin)
+
+	// Add a log, which can't be added twice
+	status, body := adminRequest(t, srv, http.MethodPost, "/admin/logs", adminToken, logKeyBody(key))
+	if status != http.StatusCreated {
+		t.Fatalf("POST /admin/logs status = %d, want 201: %s", status, body)
+	}
+	var added TrackedLog
+	if err := json.Unmarshal([]byte(body), &added); err != nil || added.Origin != origin || added.PublicKey != key || added.TreeSize != 0 {
+		t.Errorf("POST /admin/logs = %+v, %v, want log %s with key %s", added, err, origin, key)
+	}
+	if status, body := adminRequest(t, srv, http.MethodPost, "/admin/logs", adminToken, logKeyBody(key)); status != http.StatusConflict {
+		t.Errorf("POST /admin/logs for tracked log status = %d, want 409: %s", status, body)
+	}
+
+	// The witness's verified size and hash are shown
+	if err := store.CompareAndSwapCheckpoint(t.Context(), origin, 0, db.Checkpoint{Size: 5, Hash: []byte("hash")}); err != nil {
+		t.Fatalf("CompareAndSwapCheckpoint() error = %v", err)
+	}
+	status, body = adminRequest(t, srv, http.MethodGet, "/admin/logs/"+origin, adminToken, "")
+	var got TrackedLog
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &got) != nil || got.TreeSize != 5 || string(got.TreeHash) != "hash" {
+		t.Errorf("GET /admin/logs/%s = %d %s, want log with size 5", origin, status, body)
+	}
+	status, body = adminRequest(t, srv, http.MethodGet, "/admin/logs", adminToken, "")
+	var logs []TrackedLog
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &logs) != nil || len(logs) != 1 || logs[0].Origin != origin {
+		t.Errorf("GET /admin/logs = %d %s, want only %s", status, body, origin)
+	}
+
+	// Replacing the key keeps the verified size
+	newKey := testVerifierKey(t, origin)
+	status, body = adminRequest(t, srv, http.MethodPut, "/admin/logs/"+origin, adminToken, logKeyBody(newKey))
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &got) != nil || got.PublicKey != newKey || got.TreeSize != 5 {
+		t.Errorf("PUT /admin/logs/%s = %d %s, want log with new key and size 5", origin, status, body)
+	}
+
+	// Removing the log leaves no tracked logs
+	if status, body := adminRequest(t, srv, http.MethodDelete, "/admin/logs/"+origin, adminToken, ""); status != http.StatusNoContent {
+		t.Errorf("DELETE /admin/logs/%s status = %d, want 204: %s", origin, status, body)
+	}
+	if status, body := adminRequest(t, srv, http.MethodGet, "/admin/logs", adminToken, ""); status != http.StatusOK || strings.TrimSpace(body) != "[]" {
+		t.Errorf("GET /admin/logs after removing log = %d %s, want no logs", status, body)
+	}
+
+	for name, tc := range map[string]struct {
+		method, path, body string
+		want               int
+	}{
+		"add invalid JSON":      {method: http.MethodPost, path: "/admin/logs", body: "{", want: http.StatusBadRequest},
+		"add invalid key":       {method: http.MethodPost, path: "/admin/logs", body: logKeyBody("not a key"), want: http.StatusBadRequest},
+		"replace with key name": {method: http.MethodPut, path: "/admin/logs/a.example", body: logKeyBody(testVerifierKey(t, "b.example")), want: http.StatusBadRequest},
+		"replace unknown":       {method: http.MethodPut, path: "/admin/logs/a.example", body: logKeyBody(testVerifierKey(t, "a.example")), want: http.StatusNotFound},
+		"get unknown":           {method: http.MethodGet, path: "/admin/logs/a.example", want: http.StatusNotFound},
+		"remove unknown":        {method: http.MethodDelete, path: "/admin/logs/a.example", want: http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if status, body := adminRequest(t, srv, tc.method, tc.path, adminToken, tc.body); status != tc.want {
+				t.Errorf("%s %s status = %d, want %d: %s", tc.method, tc.path, status, tc.want, body)
+			}
+		})
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":   true,
+		"127.0.0.1":   true,
+		"::1":         true,
+		"0.0.0.0":     false,
+		"":            false,
+		"example.com": false,
+		"10.0.0.1":    false,
+	} {
+		if got := isLoopback(host); got != want {
+			t.Errorf("isLoopback(%q) = %t, want %t", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/db"
+	"github.com/haydentherapper/bt-log/internal/keyring"
+	tlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// cosigner verifies that logs remain append-only and cosigns their checkpoints, storing the
+// latest verified checkpoint for each log
+type cosigner struct {
+	store db.WitnessStore
+	keys  *keyring.Keyring
+}
+
+// checkpointError is an error verifying a checkpoint, with the HTTP status returned to the log.
+// If hasSize is set, the error is a 409 whose body is the witness's latest verified size.
+type checkpointError struct {
+	status  int
+	hasSize bool
+	size    uint64
+	err     error
+}
+
+func (e *checkpointError) Error() string {
+	return e.err.Error()
+}
+
+func newCheckpointError(status int, format string, a ...any) *checkpointError {
+	return &checkpointError{status: status, err: fmt.Errorf(format, a...)}
+}
+
+// sizeConflict returns a 409 error with the witness's latest verified size
+func sizeConflict(size uint64, format string, a ...any) *checkpointError {
+	return &checkpointError{status: http.StatusConflict, hasSize: true, size: size, err: fmt.Errorf(format, a...)}
+}
+
+// verifiedSize returns the size of the latest checkpoint the witness verified for a log, and
+// whether the log is known by the witness
+func (wit *cosigner) verifiedSize(ctx context.Context, origin string) (uint64, bool, error) {
+	l, err := wit.store.GetLog(ctx, origin)
+	if errors.Is(err, db.ErrNotFound) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return l.TreeSize, true, nil
+}
+
+// addCheckpoint verifies the signed checkpoint is consistent with the last checkpoint verified
+// at oldSize, and returns the checkpoint cosigned by the witness. Errors that are returned to
+// the log are a *checkpointError.
+func (wit *cosigner) addCheckpoint(ctx context.Context, oldSize uint64, consistencyProof [][]byte, signedNote []byte) ([]byte, error) {
+	// Get log origin from first line of checkpoint
+	var origin string
+	if lines := strings.Split(string(signedNote), "\n"); len(lines) == 0 {
+		return nil, newCheckpointError(http.StatusBadRequest, "error splitting signed note to extract origin")
+	} else {
+		origin = lines[0]
+	}
+
+	// Lookup log verifier, size and hash for the given origin
+	l, err := wit.store.GetLog(ctx, origin)
+	if errors.Is(err, db.ErrNotFound) {
+		// Return 404 for unknown log
+		return nil, newCheckpointError(http.StatusNotFound, "origin %s not known by witness", origin)
+	} else if err != nil {
+		return nil, fmt.Errorf("error querying database by origin: %w", err)
+	}
+
+	// Load verifier for log checkpoint
+	v, err := note.NewVerifier(l.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing log public key: %w", err)
+	}
+
+	// Verify log checkpoint
+	newCp, _, newCpNote, err := tlog.ParseCheckpoint(signedNote, v.Name(), v)
+	if err != nil {
+		// Return 403 for unverifiable checkpoint (e.g. invalid key for a given origin)
+		return nil, newCheckpointError(http.StatusForbidden, "error parsing log checkpoint: %v", err)
+	}
+
+	// Old size must be equal or lower than the checkpoint size
+	if oldSize > newCp.Size {
+		// Return 400 if old size is greater than checkpoint size
+		return nil, newCheckpointError(http.StatusBadRequest, "old size must be less than or equal to the new size")
+	}
+	if oldSize != l.TreeSize {
+		// Return 409 if old size does not match last verified size,
+		// and return the current size in a header
+		// A log may send 0 as the old size if the log does not know the current state
+		// of the witness
+		return nil, sizeConflict(l.TreeSize, "old size %d and last verified size %d must match", oldSize, l.TreeSize)
+	}
+	if oldSize == newCp.Size && !reflect.DeepEqual(l.TreeHash, newCp.Hash) {
+		// Return 409 if the old size and checkpoint size match but the root hashes don't
+		return nil, newCheckpointError(http.StatusConflict, "checkpoint and previous size match, but root hashes don't")
+	}
+
+	if err := proof.VerifyConsistency(rfc6962.DefaultHasher, oldSize, newCp.Size, consistencyProof, l.TreeHash, newCp.Hash); err != nil {
+		// Return 422 if the consistency proof does not verify
+		return nil, newCheckpointError(http.StatusUnprocessableEntity, "proof did not verify: %v", err)
+	}
+
+	// Co-sign checkpoint, with both the active key and any keys being rotated out
+	cosignedCheckpoint, err := note.Sign(newCpNote, wit.keys.Signers(time.Now())...)
+	if err != nil {
+		return nil, fmt.Errorf("error cosigning checkpoint: %w", err)
+	}
+
+	// Persist verified size, hash and cosigned checkpoint. Only update where the size matches
+	// the last verified size, to prevent concurrent requests from rolling back the witness state
+	cp := db.Checkpoint{Size: newCp.Size, Hash: newCp.Hash, Note: cosignedCheckpoint}
+	if err := wit.store.CompareAndSwapCheckpoint(ctx, origin, oldSize, cp); errors.Is(err, db.ErrConflict) {
+		// If the witness has not updated the log, then a concurrent request must fail.
+		// Return a 409 with the new verified size
+		treeSize, _, err := wit.verifiedSize(ctx, origin)
+		if err != nil {
+			return nil, fmt.Errorf("error reading latest size: %w", err)
+		}
+		return nil, sizeConflict(treeSize, "checkpoint for %s was updated concurrently to size %d", origin, treeSize)
+	} else if err != nil {
+		return nil, fmt.Errorf("error updating stored checkpoint: %w", err)
+	}
+
+	return cosignedCheckpoint, nil
+}
@@ -2,38 +2,40 @@ package main
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"reflect"
 	"strconv"
 	"strings"
 
-	"github.com/haydentherapper/bt-log/internal/db/postgres"
-	tlog "github.com/transparency-dev/formats/log"
-	f_note "github.com/transparency-dev/formats/note"
-	"github.com/transparency-dev/merkle/proof"
-	"github.com/transparency-dev/merkle/rfc6962"
-	"golang.org/x/mod/sumdb/note"
-
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "modernc.org/sqlite"
+	"github.com/haydentherapper/bt-log/internal/auth"
+	"github.com/haydentherapper/bt-log/internal/db"
+	"github.com/haydentherapper/bt-log/internal/keyring"
+	"github.com/haydentherapper/bt-log/internal/rotation"
+	"github.com/haydentherapper/bt-log/internal/witness"
 )
 
 var (
-	host        = flag.String("host", "localhost", "host to listen on")
-	port        = flag.Uint("port", 8081, "port to listen on")
-	dbPath      = flag.String("database-path", "", "path to checkpoint database (for sqlite)")
-	privKeyFile = flag.String("private-key", "", "location of witness private key file")
-	pubKeyFile  = flag.String("public-key", "", "location of witness public key file")
-	dbType      = flag.String("db-type", "sqlite", "database type (sqlite, mysql, postgres)")
-	dbDSN       = flag.String("db-dsn", "", "database data source name")
+	host          = flag.String("host", "localhost", "host to listen on")
+	port          = flag.Uint("port", 8081, "port to listen on")
+	dbPath        = flag.String("database-path", "", "path to checkpoint database (for sqlite)")
+	privKeyFile   = flag.String("private-key", "", "location of witness private key file")
+	pubKeyFile    = flag.String("public-key", "", "location of witness public key file")
+	keyringFile   = flag.String("keyring", "", "location of witness keyring file, instead of --private-key and --public-key")
+	adminTokens   = flag.String("admin-token-file", "", "optional token file location to enable the admin API for managing tracked logs")
+	adminHost     = flag.String("admin-host", "localhost", "host to serve the admin API on. Requires --tls-cert unless it's a loopback address")
+	adminPort     = flag.Uint("admin-port", 8082, "port to serve the admin API on")
+	tlsCertFile   = flag.String("tls-cert", "", "optional TLS certificate location to serve HTTPS. Requires --tls-key")
+	tlsKeyFile    = flag.String("tls-key", "", "optional TLS private key location to serve HTTPS. Requires --tls-cert")
+	pullConfig    = flag.String("pull-config", "", "optional pull config file location, listing logs whose checkpoints the witness fetches and cosigns")
+	dbType        = flag.String("db-type", "sqlite", "database type (sqlite, mysql, postgres)")
+	dbDSN         = flag.String("db-dsn", "", "database data source name")
+	migrateDryRun = flag.Bool("migrations-dry-run", false, "print pending database schema migrations and exit without applying them")
 )
 
 func writeCosignatureResp(w http.ResponseWriter, cosignedCheckpoint []byte) {
@@ -68,73 +70,176 @@ func main() {
 	if *dbPath != "" && *dbType != "sqlite" {
 		log.Fatalf("--database-path can only be used with --db-type=sqlite")
 	}
-	if *privKeyFile == "" {
-		log.Fatalf("--private-key required to initialize witness")
+	if *keyringFile != "" && (*privKeyFile != "" || *pubKeyFile != "") {
+		log.Fatalf("--keyring can't be used with --private-key or --public-key")
+	}
+	if *keyringFile == "" && *privKeyFile == "" {
+		log.Fatalf("--private-key or --keyring required to initialize witness")
 	}
-	if *pubKeyFile == "" {
+	if *keyringFile == "" && *pubKeyFile == "" {
 		log.Fatalf("--public-key required to initialize witness")
 	}
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		log.Fatalf("--tls-cert and --tls-key must both be set")
+	}
+	// Admin bearer tokens must not be sent in the clear over the network
+	if *adminTokens != "" && *tlsCertFile == "" && !isLoopback(*adminHost) {
+		log.Fatalf("--tls-cert must be set to serve the admin API on non-loopback host %s", *adminHost)
+	}
 
-	var driverName, dsn string
-	rebind := func(s string) string { return s } // Default is no-op for mysql and sqlite
-
-	switch *dbType {
-	case "sqlite":
-		driverName = "sqlite"
-		if *dbDSN != "" {
-			dsn = *dbDSN
-		} else {
-			// Enable Write-Ahead Logging for better concurrency, allowing reads during writes.
-			// A busy timeout is also set to prevent "database is locked" errors under contention,
-			// with writers waiting 1s before returning an error.
-			dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=1000", *dbPath)
-		}
-	case "mysql":
-		driverName = "mysql"
-		if *dbDSN == "" {
-			log.Fatalf("--db-dsn must be set for --db-type=mysql")
+	// List pending schema migrations without applying them for a dry run
+	if *migrateDryRun {
+		pending, err := db.Migrate(context.Background(), *dbType, *dbPath, *dbDSN, true)
+		if err != nil {
+			log.Fatalf("failed to read database schema: %v", err)
 		}
-		dsn = *dbDSN
-	case "postgres":
-		driverName = "pgx"
-		if *dbDSN == "" {
-			log.Fatalf("--db-dsn must be set for --db-type=postgres")
+		for _, m := range pending {
+			log.Printf("pending migration %d: %s\n", m.Version, m.Description)
 		}
-		dsn = *dbDSN
-		rebind = postgres.Rebind
-	default:
-		log.Fatalf("unsupported --db-type: %s. Must be one of 'sqlite', 'mysql', 'postgres'", *dbType)
+		log.Printf("%d pending migrations\n", len(pending))
+		return
 	}
 
-	db, err := sql.Open(driverName, dsn)
+	// Open the database, applying pending schema migrations
+	store, applied, err := db.OpenAndMigrate(context.Background(), *dbType, *dbPath, *dbDSN)
 	if err != nil {
 		log.Fatalf("failed to open database: %v", err)
 	}
-	defer db.Close()
+	defer store.Close()
+	for _, m := range applied {
+		log.Printf("applied migration %d: %s\n", m.Version, m.Description)
+	}
 
-	// Create the table (if it doesn't already exist)
-	_, err = db.Exec(`
-			CREATE TABLE IF NOT EXISTS tlog (
-					origin VARCHAR(255) PRIMARY KEY,
-					public_key TEXT NOT NULL, -- note verifier format
-					tree_size INTEGER NOT NULL,
-					tree_hash TEXT NOT NULL -- base64-encoded
-			)
-	`)
+	// Initialize witness note signers. A single private key is a keyring with only an active key.
+	var keys *keyring.Keyring
+	if *keyringFile != "" {
+		keys, err = keyring.Load(*keyringFile, keyring.NewCosigner)
+	} else {
+		keys, err = keyring.New([]keyring.Key{{PrivateKeyPath: *privKeyFile, Active: true}}, keyring.NewCosigner)
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("failed to load witness keys: %v", err)
 	}
 
-	// Initialize witness note signer
-	privKey, err := os.ReadFile(*privKeyFile)
-	if err != nil {
-		log.Fatalf("failed to read private key file for %s: %v", *privKeyFile, err)
+	wit := &cosigner{store: store, keys: keys}
+
+	// Manage tracked logs with an admin API, authenticated with bearer tokens. The admin API is
+	// served on its own listener, so it isn't exposed with the witness API.
+	if *adminTokens != "" {
+		authn, err := auth.NewTokenAuthenticator(*adminTokens)
+		if err != nil {
+			log.Fatalf("failed to load admin tokens: %v", err)
+		}
+		adminMux := http.NewServeMux()
+		handleAdminAPI(adminMux, &adminAPI{store: store, authn: authn})
+		adminAddress := fmt.Sprintf("%s:%d", *adminHost, *adminPort)
+		log.Printf("Admin API running on %s\n", adminAddress)
+		go func() {
+			if err := serve(adminAddress, adminMux); err != nil {
+				log.Fatalf("admin ListenAndServe: %v", err)
+			}
+		}()
 	}
-	witnessSigner, err := f_note.NewSignerForCosignatureV1(string(privKey))
-	if err != nil {
-		log.Fatalf("failed to read signer %s: %v", *privKeyFile, err)
+
+	// Fetch and cosign checkpoints from logs that don't push them to the witness
+	if *pullConfig != "" {
+		cfg, err := witness.LoadPullConfig(*pullConfig)
+		if err != nil {
+			log.Fatalf("failed to load pull config: %v", err)
+		}
+		p, err := newPuller(wit, cfg)
+		if err != nil {
+			log.Fatalf("failed to initialize pull mode: %v", err)
+		}
+		go p.run(context.Background())
 	}
 
+	// Publish the verifier keys of the keyring, starting with the active key
+	http.HandleFunc("GET /public-keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(strings.Join(keys.PublicKeys(), "\n") + "\n")); err != nil {
+			log.Printf("/public-keys: %v", err)
+		}
+	})
+
+	// Serve the latest checkpoint the witness has verified and cosigned for a log, including the
+	// log's signature and the witness's cosignatures, so clients can compare it with the log's view
+	http.HandleFunc("GET /checkpoint/{origin...}", func(w http.ResponseWriter, r *http.Request) {
+		l, err := store.GetLog(r.Context(), r.PathValue("origin"))
+		if errors.Is(err, db.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("unknown origin"))
+			return
+		} else if err != nil {
+			log.Printf("error querying database by origin: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// A log's checkpoint is stored once the witness has cosigned one
+		if l.Checkpoint == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("no checkpoint cosigned for origin"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		if _, err := w.Write(l.Checkpoint); err != nil {
+			log.Printf("/checkpoint: %v", err)
+		}
+	})
+
+	// Follow a log key rotation, replacing the log's verifier key with the new key if the
+	// request body is a key rotation statement signed by the log's current key and the new key
+	http.HandleFunc("POST /key-rotation", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		st, err := rotation.Parse(b)
+		if err != nil {
+			log.Printf("error parsing key rotation statement: %v\n", err)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		l, err := store.GetLog(r.Context(), st.Origin)
+		if errors.Is(err, db.ErrNotFound) {
+			// Return 404 for unknown log
+			log.Printf("origin %s not known by witness\n", st.Origin)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("error querying database by origin: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// The rotation may already have been followed
+		if strings.TrimSpace(l.PublicKey) == st.NewKey {
+			return
+		}
+		if _, err := rotation.Verify(b, l.PublicKey); err != nil {
+			// Return 403 if the log's current key didn't endorse the rotation
+			log.Printf("error verifying key rotation statement: %v\n", err)
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Only update the key the statement was verified with, in case of a concurrent rotation
+		if err := store.CompareAndSwapPublicKey(r.Context(), st.Origin, l.PublicKey, st.NewKey); errors.Is(err, db.ErrConflict) {
+			log.Printf("log key for %s changed while following key rotation\n", st.Origin)
+			w.WriteHeader(http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Printf("error updating log key: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Printf("followed key rotation for %s to %s\n", st.Origin, st.NewKey)
+	})
+
 	// Request body must be:
 	// - an old size line,
 	// - zero or more consistency proof lines,
@@ -194,163 +299,39 @@ func main() {
 			consistencyProof = append(consistencyProof, rawProof)
 		}
 
-		// Get log origin from first line of checkpoint
-		var origin string
-		if lines := strings.Split(string(signedNote), "\n"); len(lines) == 0 {
-			log.Printf("error splitting signed note to extract origin\n")
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		} else {
-			origin = lines[0]
-		}
-
-		// Lookup log verifier, size and hash for the given origin
-		query := rebind("SELECT public_key, tree_size, tree_hash FROM tlog WHERE origin = ?")
-		rows, err := db.Query(query, origin)
-		if err != nil {
-			log.Printf("error querying database by origin: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		// At most one row will be selected since origin is a primary key
-		var publicKey string
-		var treeSize uint64
-		var treeHashB64 string
-		for rows.Next() {
-			if err := rows.Scan(&publicKey, &treeSize, &treeHashB64); err != nil {
-				log.Printf("error scanning row: %v\n", err)
-				w.WriteHeader(http.StatusInternalServerError)
+		cosignedCheckpoint, err := wit.addCheckpoint(r.Context(), oldSize, consistencyProof, signedNote)
+		var cpErr *checkpointError
+		if errors.As(err, &cpErr) {
+			log.Printf("%v\n", err)
+			if cpErr.hasSize {
+				w.Header().Set("Content-Type", "text/x.tlog.size")
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(fmt.Sprintf("%d", cpErr.size)))
 				return
 			}
-		}
-		// If public key is empty, no row was selected, so the origin is unknown
-		if publicKey == "" {
-			// Return 404 for unknown log
-			log.Printf("origin %s not known by witness\n", origin)
-			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(cpErr.status)
 			return
-		}
-
-		treeHash, err := base64.StdEncoding.DecodeString(treeHashB64)
-		if err != nil {
-			log.Printf("error parsing tree hash: %v\n", err)
+		} else if err != nil {
+			log.Printf("%v\n", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		// Load verifier for log checkpoint
-		v, err := note.NewVerifier(publicKey)
-		if err != nil {
-			log.Printf("error parsing log public key: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		// Verify log checkpoint
-		newCp, _, newCpNote, err := tlog.ParseCheckpoint(signedNote, v.Name(), v)
-		if err != nil {
-			// Return 403 for unverifiable checkpoint (e.g. invalid key for a given origin)
-			log.Printf("error parsing log checkpoint: %v\n", err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-
-		// Old size must be equal or lower than the checkpoint size
-		if oldSize > newCp.Size {
-			// Return 400 if old size is greater than checkpoint size
-			log.Printf("old size must be less than or equal to the new size\n")
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		if oldSize != treeSize {
-			// Return 409 if old size does not match last verified size,
-			// and return the current size in a header
-			// A log may send 0 as the old size if the log does not know the current state
-			// of the witness
-			log.Printf("old size %d and last verified size %d must match\n", oldSize, treeSize)
-			w.Header().Set("Content-Type", "text/x.tlog.size")
-			w.WriteHeader(http.StatusConflict)
-			_, _ = w.Write([]byte(fmt.Sprintf("%d", treeSize)))
-			return
-		}
-		if oldSize == newCp.Size && !reflect.DeepEqual(treeHash, newCp.Hash) {
-			// Return 409 if the old size and checkpoint size match but the root hashes don't
-			log.Println("checkpoint and previous size match, but root hashes don't")
-			w.WriteHeader(http.StatusConflict)
-			return
-		}
-
-		if err := proof.VerifyConsistency(rfc6962.DefaultHasher, oldSize, newCp.Size, consistencyProof, treeHash, newCp.Hash); err != nil {
-			// Return 422 if the consistency proof does not verify
-			log.Printf("proof did not verify: %v\n", err)
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			return
-		}
-
-		// Co-sign checkpoint
-		cosignedCheckpoint, err := note.Sign(newCpNote, witnessSigner)
-		if err != nil {
-			log.Printf("error cosigning checkpoint: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		// If the checkpoint is identical to what we've already seen, return the cosigned checkpoint.
-		// This is necessary since MySQL's RowsAffected behavior for no-op UPDATEs is different
-		// than other databases and won't register an update if the column values are identical.
-		if oldSize == newCp.Size && reflect.DeepEqual(treeHash, newCp.Hash) {
-			writeCosignatureResp(w, cosignedCheckpoint)
-			return
-		}
-
-		// Persist verified size and hash. Only update where tree_size matches the last verified size,
-		// to prevent concurrent requests from rolling back the witness state
-		updateQuery := rebind("UPDATE tlog SET tree_size = ?, tree_hash = ? WHERE origin = ? AND tree_size = ?")
-		if r, err := db.Exec(updateQuery,
-			newCp.Size, base64.StdEncoding.EncodeToString(newCp.Hash), origin, oldSize); err != nil {
-			log.Printf("error updating stored checkpoint: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		} else if c, err := r.RowsAffected(); err != nil {
-			log.Printf("error reading rows after storing checkpoint: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		} else if c != 1 {
-			// If the witness has not updated a row, then a concurrent request must fail.
-			// Return a 409 with the new verified size
-			selectQuery := rebind("SELECT tree_size FROM tlog WHERE origin = ?")
-			rows, err := db.Query(selectQuery, origin)
-			if err != nil {
-				log.Printf("error reading latest size: %v\n", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			defer rows.Close()
-
-			var treeSize uint64
-			for rows.Next() {
-				if err := rows.Scan(&treeSize); err != nil {
-					log.Printf("error reading tree size from returned row: %v\n", err)
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-			}
-
-			w.Header().Set("Content-Type", "text/x.tlog.size")
-			w.WriteHeader(http.StatusConflict)
-			_, _ = w.Write([]byte(fmt.Sprintf("%d", treeSize)))
-			return
-		}
-
 		writeCosignatureResp(w, cosignedCheckpoint)
 	})
 
 	address := fmt.Sprintf("%s:%d", *host, *port)
 	log.Printf("Server running on %s\n", address)
 
-	if err := http.ListenAndServe(address, http.DefaultServeMux); err != nil {
+	if err := serve(address, http.DefaultServeMux); err != nil {
 		log.Fatalf("ListenAndServe: %v", err)
 	}
 }
+
+// serve serves HTTP on the address, or HTTPS if a TLS certificate is set
+func serve(address string, handler http.Handler) error {
+	if *tlsCertFile != "" {
+		return http.ListenAndServeTLS(address, *tlsCertFile, *tlsKeyFile, handler)
+	}
+	return http.ListenAndServe(address, handler)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/db"
+	"github.com/haydentherapper/bt-log/internal/witness"
+	tlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/tessera/client"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// pulledLog is a log the witness fetches checkpoints and tiles from
+type pulledLog struct {
+	origin  string
+	fetcher *client.HTTPFetcher
+}
+
+// puller periodically cosigns the latest checkpoint of each configured log, and posts new
+// cosigned checkpoints to an optional webhook
+type puller struct {
+	wit        *cosigner
+	logs       []pulledLog
+	interval   time.Duration
+	webhookURL string
+	httpClient *http.Client
+}
+
+// newPuller returns a puller for the configured logs, adding logs the witness doesn't yet track
+func newPuller(wit *cosigner, cfg *witness.PullConfig) (*puller, error) {
+	p := &puller{wit: wit, interval: cfg.Interval.Duration, webhookURL: cfg.WebhookURL, httpClient: http.DefaultClient}
+	for _, l := range cfg.Logs {
+		if err := wit.trackLog(context.Background(), l.PublicKey); err != nil {
+			return nil, fmt.Errorf("failed to add log %s: %w", l.Origin, err)
+		}
+		u, err := url.Parse(l.URL)
+		if err != nil {
+			return nil, fmt.Errorf("log %s has invalid URL: %w", l.Origin, err)
+		}
+		// Tiles are fetched relative to the log's root, which must end in a slash
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		fetcher, err := client.NewHTTPFetcher(u, p.httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("error creating HTTP client for log %s: %w", l.Origin, err)
+		}
+		p.logs = append(p.logs, pulledLog{origin: l.Origin, fetcher: fetcher})
+	}
+	return p, nil
+}
+
+// run pulls each log every interval until the context is done
+func (p *puller) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		for _, l := range p.logs {
+			if err := p.pull(ctx, l); err != nil {
+				log.Printf("error pulling checkpoint for %s: %v\n", l.origin, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pull cosigns the log's latest checkpoint with a consistency proof built from the log's tiles.
+// A cosigned checkpoint that the witness hadn't verified before is posted to the webhook.
+func (p *puller) pull(ctx context.Context, l pulledLog) error {
+	oldSize, known, err := p.wit.verifiedSize(ctx, l.origin)
+	if err != nil {
+		return fmt.Errorf("error reading latest size: %w", err)
+	} else if !known {
+		return fmt.Errorf("origin %s not known by witness", l.origin)
+	}
+	signedNote, err := l.fetcher.ReadCheckpoint(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	// The checkpoint is verified when it's cosigned. Until then, its size is only used to
+	// build a consistency proof.
+	text, _, _ := bytes.Cut(signedNote, []byte("\n\n"))
+	var cp tlog.Checkpoint
+	if _, err := cp.Unmarshal(append(text, '\n')); err != nil {
+		return fmt.Errorf("error parsing checkpoint: %w", err)
+	}
+	if cp.Origin != l.origin {
+		return fmt.Errorf("checkpoint has origin %s", cp.Origin)
+	}
+	if cp.Size < oldSize {
+		return fmt.Errorf("checkpoint size %d is smaller than verified size %d", cp.Size, oldSize)
+	}
+	var consistencyProof [][]byte
+	if oldSize > 0 && cp.Size > oldSize {
+		pb, err := client.NewProofBuilder(ctx, cp.Size, l.fetcher.ReadTile)
+		if err != nil {
+			return fmt.Errorf("error creating proof builder: %w", err)
+		}
+		consistencyProof, err = pb.ConsistencyProof(ctx, oldSize, cp.Size)
+		if err != nil {
+			return fmt.Errorf("error building consistency proof: %w", err)
+		}
+	}
+
+	cosignedCheckpoint, err := p.wit.addCheckpoint(ctx, oldSize, consistencyProof, signedNote)
+	if err != nil {
+		return err
+	}
+	// Checkpoints are only posted once, when the log grows
+	if p.webhookURL == "" || cp.Size == oldSize {
+		return nil
+	}
+	return p.notify(ctx, cosignedCheckpoint)
+}
+
+// notify posts the cosigned checkpoint to the webhook. This isn't a standard distributor
+// protocol: the body is only the signed note, with the log's signature followed by the
+// witness's cosignatures, and any 2xx response is a success.
+func (p *puller) notify(ctx context.Context, cosignedCheckpoint []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.webhookURL, bytes.NewReader(cosignedCheckpoint))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// trackLog adds a log the witness doesn't yet track, which is verified from an empty tree.
+// A log that's already tracked keeps its stored key, which may have been rotated since.
+func (wit *cosigner) trackLog(ctx context.Context, publicKey string) error {
+	v, err := note.NewVerifier(publicKey)
+	if err != nil {
+		return err
+	}
+	if err := wit.store.AddLog(ctx, v.Name(), publicKey); errors.Is(err, db.ErrExists) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	log.Printf("added log %s from pull config\n", v.Name())
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/db"
+	"github.com/haydentherapper/bt-log/internal/keyring"
+	"github.com/haydentherapper/bt-log/internal/witness"
+	f_note "github.com/transparency-dev/formats/note"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/storage/posix"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// testPulledLog is a log on local storage, served over HTTP for the witness to pull
+type testPulledLog struct {
+	origin   string
+	vkey     string
+	url      string
+	appender *tessera.Appender
+	await    *tessera.PublicationAwaiter
+}
+
+func newTestPulledLog(t *testing.T, origin string) *testPulledLog {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	skey, vkey, err := note.GenerateKey(rand.Reader, origin)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	dir := t.TempDir()
+	driver, err := posix.New(ctx, posix.Config{Path: dir})
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	appender, shutdown, r, err := tessera.NewAppender(ctx, driver, tessera.NewAppendOptions().
+		WithCheckpointSigner(signer).
+		WithCheckpointInterval(100*time.Millisecond).
+		WithBatching(256, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create appender: %v", err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(func() {
+		srv.Close()
+		_ = shutdown(ctx)
+		cancel()
+	})
+	return &testPulledLog{
+		origin:   origin,
+		vkey:     vkey,
+		url:      srv.URL,
+		appender: appender,
+		await:    tessera.NewPublicationAwaiter(ctx, r.ReadCheckpoint, 10*time.Millisecond),
+	}
+}
+
+// add appends n entries, waiting until they're published in a checkpoint
+func (l *testPulledLog) add(t *testing.T, n int) {
+	t.Helper()
+	for i := range n {
+		f := l.appender.Add(t.Context(), tessera.NewEntry([]byte(fmt.Sprintf("entry %d", i))))
+		if _, _, err := l.await.Await(t.Context(), f); err != nil {
+			t.Fatalf("failed to publish entry: %v", err)
+		}
+	}
+}
+
+// testWebhook records the cosigned checkpoints posted to it, responding with status
+type testWebhook struct {
+	mu          sync.Mutex
+	status      int
+	checkpoints []string
+}
+
+func (d *testWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checkpoints = append(d.checkpoints, string(b))
+	w.WriteHeader(d.status)
+}
+
+func (d *testWebhook) posted() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.checkpoints...)
+}
+
+func (d *testWebhook) setStatus(status int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = status
+}
+
+// newTestCosigner returns a witness with a new database, and its cosignature verifier
+func newTestCosigner(t *testing.T) (*cosigner, note.Verifier) {
+	t.Helper()
+	store, err := db.Open("sqlite", filepath.Join(t.TempDir(), "witness.db"), "")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	skey, vkey, err := note.GenerateKey(rand.Reader, "witness.example.com")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "witness.key")
+	if err := os.WriteFile(path, []byte(skey), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	keys, err := keyring.New([]keyring.Key{{PrivateKeyPath: path, Active: true}}, keyring.NewCosigner)
+	if err != nil {
+		t.Fatalf("failed to load keyring: %v", err)
+	}
+	v, err := f_note.NewVerifierForCosignatureV1(vkey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	return &cosigner{store: store, keys: keys}, v
+}
+
+func TestPull(t *testing.T) {
+	ctx := t.Context()
+	l := newTestPulledLog(t, "log.example.com")
+	wit, witVerifier := newTestCosigner(t)
+	hook := &testWebhook{status: http.StatusNoContent}
+	hookSrv := httptest.NewServer(hook)
+	t.Cleanup(hookSrv.Close)
+
+	p, err := newPuller(wit, &witness.PullConfig{
+		WebhookURL: hookSrv.URL + "/checkpoints",
+		Logs:       []witness.PullLog{{Origin: l.origin, PublicKey: l.vkey, URL: l.url}},
+	})
+	if err != nil {
+		t.Fatalf("newPuller() error = %v", err)
+	}
+	// Logs in the pull config are tracked from an empty tree
+	if size, known, err := wit.verifiedSize(ctx, l.origin); err != nil || !known || size != 0 {
+		t.Fatalf("verifiedSize() after newPuller = %d, %t, %v, want tracked log with size 0", size, known, err)
+	}
+	logVerifier, err := note.NewVerifier(l.vkey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	// Each pull cosigns the log's latest checkpoint, with a consistency proof once the witness
+	// has verified a checkpoint
+	for i, n := range []int{3, 2, 5} {
+		l.add(t, n)
+		if err := p.pull(ctx, p.logs[0]); err != nil {
+			t.Fatalf("pull() error = %v", err)
+		}
+		size, _, err := wit.verifiedSize(ctx, l.origin)
+		if err != nil {
+			t.Fatalf("verifiedSize() error = %v", err)
+		}
+		posted := hook.posted()
+		if len(posted) != i+1 {
+			t.Fatalf("webhook received %d checkpoints, want %d", len(posted), i+1)
+		}
+		// The posted checkpoint is signed by the log and cosigned by the witness
+		cp, err := note.Open([]byte(posted[i]), note.VerifierList(logVerifier, witVerifier))
+		if err != nil || len(cp.Sigs) != 2 {
+			t.Fatalf("posted checkpoint %q doesn't verify: %+v, %v", posted[i], cp, err)
+		}
+		if want := fmt.Sprintf("%s\n%d\n", l.origin, size); !strings.HasPrefix(cp.Text, want) {
+			t.Errorf("posted checkpoint = %q, want checkpoint for verified size %d", cp.Text, size)
+		}
+	}
+
+	// A checkpoint that the witness already verified is cosigned, but not posted again
+	if err := p.pull(ctx, p.logs[0]); err != nil {
+		t.Fatalf("pull() without new entries error = %v", err)
+	}
+	if posted := hook.posted(); len(posted) != 3 {
+		t.Errorf("webhook received %d checkpoints after pull without new entries, want 3", len(posted))
+	}
+
+	// Webhook errors are returned, after the witness has verified the checkpoint
+	hook.setStatus(http.StatusInternalServerError)
+	l.add(t, 1)
+	if err := p.pull(ctx, p.logs[0]); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("pull() with failing webhook error = %v, want webhook error", err)
+	}
+	if size, _, err := wit.verifiedSize(ctx, l.origin); err != nil || size != 11 {
+		t.Errorf("verifiedSize() after failing webhook = %d, %v, want 11", size, err)
+	}
+}
+
+func TestPullInvalid(t *testing.T) {
+	ctx := t.Context()
+	l := newTestPulledLog(t, "log.example.com")
+	other := newTestPulledLog(t, "other.example.com")
+	l.add(t, 2)
+	wit, _ := newTestCosigner(t)
+
+	// A log that's already tracked keeps its stored key, which may have been rotated
+	_, rotatedKey, err := note.GenerateKey(rand.Reader, l.origin)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if err := wit.store.AddLog(ctx, l.origin, rotatedKey); err != nil {
+		t.Fatalf("AddLog() error = %v", err)
+	}
+	p, err := newPuller(wit, &witness.PullConfig{Logs: []witness.PullLog{
+		{Origin: l.origin, PublicKey: l.vkey, URL: l.url},
+		// The log is served at another log's URL
+		{Origin: other.origin, PublicKey: other.vkey, URL: l.url},
+	}})
+	if err != nil {
+		t.Fatalf("newPuller() error = %v", err)
+	}
+	if stored, err := wit.store.GetLog(ctx, l.origin); err != nil || stored.PublicKey != rotatedKey {
+		t.Errorf("GetLog() after newPuller = %+v, %v, want rotated key %s", stored, err, rotatedKey)
+	}
+
+	for i, want := range []string{
+		"error parsing log checkpoint",
+		"checkpoint has origin log.example.com",
+	} {
+		if err := p.pull(ctx, p.logs[i]); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("pull() for %s error = %v, want %q", p.logs[i].origin, err, want)
+		}
+		if size, _, err := wit.verifiedSize(ctx, p.logs[i].origin); err != nil || size != 0 {
+			t.Errorf("verifiedSize() for %s = %d, %v, want 0", p.logs[i].origin, size, err)
+		}
+	}
+
+	// Logs that the witness doesn't track aren't pulled
+	if err := wit.store.DeleteLog(ctx, l.origin); err != nil {
+		t.Fatalf("DeleteLog() error = %v", err)
+	}
+	if err := p.pull(ctx, p.logs[0]); err == nil || !strings.Contains(err.Error(), "not known by witness") {
+		t.Errorf("pull() for untracked log error = %v, want unknown origin error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request doesn't contain
+// the type of credential it checks
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator returns the identity of the submitter of a request. Identities are prefixed
+// by the type of credential, e.g. token:alice, so that authenticators can't be used to
+// impersonate each other's identities.
+type Authenticator interface {
+	Authenticate(r *http.Request) (string, error)
+}
+
+// Chain authenticates a request with the first Authenticator for which the request
+// contains credentials
+type Chain []Authenticator
+
+// Authenticate returns the identity from the first Authenticator that doesn't return
+// ErrNoCredentials. Returns ErrNoCredentials if no Authenticator found credentials.
+func (c Chain) Authenticate(r *http.Request) (string, error) {
+	for _, a := range c {
+		identity, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return "", ErrNoCredentials
+}
+
+// bearerToken returns the token from an Authorization: Bearer header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// TokenAuthenticator authenticates requests with a bearer token listed in a token file
+type TokenAuthenticator struct {
+	// SHA256 hash of each token to its identity
+	tokens map[[sha256.Size]byte]string
+}
+
+// NewTokenAuthenticator reads a token file, where each line contains an identity and the
+// hex-encoded SHA256 hash of its token, separated by whitespace. Lines starting with # are ignored.
+func NewTokenAuthenticator(path string) (*TokenAuthenticator, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	t := &TokenAuthenticator{tokens: make(map[[sha256.Size]byte]string)}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("token file line %d must contain an identity and a token hash", line)
+		}
+		hash, err := hex.DecodeString(fields[1])
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("token file line %d must contain a hex-encoded SHA256 token hash", line)
+		}
+		t.tokens[[sha256.Size]byte(hash)] = fields[0]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Authenticate returns token:{identity} for the request's bearer token
+func (t *TokenAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token, ok := bearerToken(r)
+	// JWTs are checked by JWTAuthenticator
+	if !ok || isJWT(token) {
+		return "", ErrNoCredentials
+	}
+	// Tokens are looked up by hash, so lookup time doesn't depend on the token
+	identity, ok := t.tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		return "", fmt.Errorf("unknown bearer token")
+	}
+	return "token:" + identity, nil
+}
+
+// CertAuthenticator authenticates requests with a TLS client certificate that was
+// verified by the server
+type CertAuthenticator struct{}
+
+// Authenticate returns cert:{identity} from the verified client certificate. The identity is
+// the first URI SAN, else the first email SAN, else the subject common name.
+func (CertAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrNoCredentials
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	switch {
+	case len(cert.URIs) > 0:
+		return "cert:" + cert.URIs[0].String(), nil
+	case len(cert.EmailAddresses) > 0:
+		return "cert:" + cert.EmailAddresses[0], nil
+	case cert.Subject.CommonName != "":
+		return "cert:" + cert.Subject.CommonName, nil
+	}
+	return "", fmt.Errorf("client certificate contains no identity")
+}
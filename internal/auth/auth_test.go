@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(contents), 0o600); err != nil {
+		t.Fatalf("error writing %s: %v", name, err)
+	}
+	return p
+}
+
+func tokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	p := writeFile(t, "tokens", "# publisher tokens\nalice "+tokenHash("alice-token")+"\n\nbob "+tokenHash("bob-token")+"\n")
+	a, err := NewTokenAuthenticator(p)
+	if err != nil {
+		t.Fatalf("NewTokenAuthenticator() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantIdentity  string
+		wantErr       error
+	}{
+		{name: "Known token", authorization: "Bearer alice-token", wantIdentity: "token:alice"},
+		{name: "Lowercase scheme", authorization: "bearer bob-token", wantIdentity: "token:bob"},
+		{name: "Unknown token", authorization: "Bearer mallory-token", wantErr: errors.New("unknown bearer token")},
+		{name: "No header", wantErr: ErrNoCredentials},
+		{name: "Basic auth", authorization: "Basic YWxpY2U6cGFzcw==", wantErr: ErrNoCredentials},
+		{name: "JWT", authorization: "Bearer " + base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + ".b.c", wantErr: ErrNoCredentials},
+		{name: "Token with dots", authorization: "Bearer a.b.c", wantErr: errors.New("unknown bearer token")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/add", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			identity, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || identity != tt.wantIdentity {
+				t.Errorf("Authenticate() = %s, %v, want %s", identity, err, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestNewTokenAuthenticatorInvalid(t *testing.T) {
+	for _, contents := range []string{
+		"alice",
+		"alice not-hex",
+		"alice " + tokenHash("token")[:32],
+		"alice " + tokenHash("token") + " extra",
+	} {
+		if _, err := NewTokenAuthenticator(writeFile(t, "tokens", contents)); err == nil {
+			t.Errorf("NewTokenAuthenticator(%q) expected error, got nil", contents)
+		}
+	}
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/publisher")
+	tests := []struct {
+		name         string
+		cert         *x509.Certificate
+		wantIdentity string
+		wantErr      bool
+	}{
+		{
+			name:         "URI SAN",
+			cert:         &x509.Certificate{URIs: []*url.URL{spiffe}, EmailAddresses: []string{"alice@example.com"}},
+			wantIdentity: "cert:spiffe://example.com/publisher",
+		},
+		{
+			name:         "Email SAN",
+			cert:         &x509.Certificate{EmailAddresses: []string{"alice@example.com"}, Subject: pkix.Name{CommonName: "alice"}},
+			wantIdentity: "cert:alice@example.com",
+		},
+		{
+			name:         "Common name",
+			cert:         &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}},
+			wantIdentity: "cert:alice",
+		},
+		{
+			name:    "No identity",
+			cert:    &x509.Certificate{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/add", nil)
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.cert}}}
+			identity, err := CertAuthenticator{}.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if identity != tt.wantIdentity {
+				t.Errorf("Authenticate() = %s, want %s", identity, tt.wantIdentity)
+			}
+		})
+	}
+
+	// Unverified certificates aren't accepted
+	r := httptest.NewRequest("POST", "/add", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "alice"}}}}
+	if _, err := (CertAuthenticator{}).Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Authenticate() with unverified certificate error = %v, want %v", err, ErrNoCredentials)
+	}
+}
+
+func TestChain(t *testing.T) {
+	tokens, err := NewTokenAuthenticator(writeFile(t, "tokens", "alice "+tokenHash("alice-token")))
+	if err != nil {
+		t.Fatalf("NewTokenAuthenticator() error = %v", err)
+	}
+	c := Chain{CertAuthenticator{}, tokens}
+
+	r := httptest.NewRequest("POST", "/add", nil)
+	if _, err := c.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Authenticate() without credentials error = %v, want %v", err, ErrNoCredentials)
+	}
+	r.Header.Set("Authorization", "Bearer alice-token")
+	if identity, err := c.Authenticate(r); err != nil || identity != "token:alice" {
+		t.Errorf("Authenticate() = %s, %v, want token:alice", identity, err)
+	}
+	r.Header.Set("Authorization", "Bearer mallory-token")
+	if _, err := c.Authenticate(r); err == nil || errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Authenticate() with invalid token error = %v, want invalid token error", err)
+	}
+}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// JWTAuthenticator authenticates requests with an OIDC ID token passed as a bearer token,
+// verified against a local JSON Web Key Set. The identity is oidc:{issuer}/{subject}.
+type JWTAuthenticator struct {
+	keys     map[string]verificationKey // Key ID to key
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// minRSAKeyBits is the smallest RSA modulus accepted for token signing keys
+const minRSAKeyBits = 2048
+
+// tokenAlgorithms are the only signature algorithms accepted for tokens, one per key type
+var tokenAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.ES256, jose.EdDSA}
+
+// verificationKey is a token signing key, and the one algorithm that tokens signed by it may use
+type verificationKey struct {
+	key crypto.PublicKey
+	alg jose.SignatureAlgorithm
+}
+
+// jwk is a JSON Web Key, per RFC 7517. Only RSA, P-256 and Ed25519 keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWTAuthenticator reads a JWKS file, and returns an authenticator for tokens signed by
+// one of its keys with the given issuer and audience
+func NewJWTAuthenticator(jwksPath, issuer, audience string) (*JWTAuthenticator, error) {
+	if issuer == "" || audience == "" {
+		return nil, fmt.Errorf("issuer and audience must be set")
+	}
+	b, err := os.ReadFile(jwksPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, fmt.Errorf("JWKS file contains no keys")
+	}
+	j := &JWTAuthenticator{keys: make(map[string]verificationKey), issuer: issuer, audience: audience, now: time.Now}
+	for _, k := range jwks.Keys {
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %q: %w", k.Kid, err)
+		}
+		if _, ok := j.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", k.Kid)
+		}
+		j.keys[k.Kid] = key
+	}
+	return j, nil
+}
+
+// verificationKey parses the key, and checks that its declared use and algorithm, if any,
+// match the algorithm for its key type
+func (k jwk) verificationKey() (verificationKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return verificationKey{}, fmt.Errorf("key use must be sig, was %s", k.Use)
+	}
+	pub, err := k.publicKey()
+	if err != nil {
+		return verificationKey{}, err
+	}
+	var alg jose.SignatureAlgorithm
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		alg = jose.RS256
+	case *ecdsa.PublicKey:
+		alg = jose.ES256
+	case ed25519.PublicKey:
+		alg = jose.EdDSA
+	}
+	if k.Alg != "" && k.Alg != string(alg) {
+		return verificationKey{}, fmt.Errorf("key algorithm must be %s for a %s key, was %s", alg, k.Kty, k.Alg)
+	}
+	return verificationKey{key: pub, alg: alg}, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits, was %d", minRSAKeyBits, pub.N.BitLen())
+		}
+		if pub.E < 3 || pub.E%2 == 0 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return pub, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("invalid P-256 point")
+		}
+		// Parse the uncompressed point to check it's on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// isJWT reports whether a bearer token is a compact JWS, i.e. three segments where the first
+// is a JSON header with an algorithm. Other tokens are left to other authenticators, even if
+// they contain dots.
+func isJWT(token string) bool {
+	header, _, ok := strings.Cut(token, ".")
+	if !ok || strings.Count(token, ".") != 2 {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+	var h struct {
+		Alg *string `json:"alg"`
+	}
+	return json.Unmarshal(b, &h) == nil && h.Alg != nil
+}
+
+// Authenticate verifies the request's bearer token, and returns oidc:{issuer}/{subject}
+func (j *JWTAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token, ok := bearerToken(r)
+	if !ok || !isJWT(token) {
+		return "", ErrNoCredentials
+	}
+	claims, err := j.verify(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	return "oidc:" + claims.Issuer + "/" + claims.Subject, nil
+}
+
+func (j *JWTAuthenticator) verify(token string) (*jwt.Claims, error) {
+	// Tokens with any other algorithm, including none and HMAC algorithms, are rejected here
+	tok, err := jwt.ParseSigned(token, tokenAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	header := tok.Headers[0]
+	if typ, ok := header.ExtraHeaders[jose.HeaderType]; ok {
+		if s, _ := typ.(string); !strings.EqualFold(s, "JWT") {
+			return nil, fmt.Errorf("token type must be JWT, was %v", typ)
+		}
+	}
+	key, ok := j.keys[header.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", header.KeyID)
+	}
+	// Each key only verifies its own algorithm, so a token can't select another algorithm for it
+	if header.Algorithm != string(key.alg) {
+		return nil, fmt.Errorf("algorithm must be %s for key %q, was %s", key.alg, header.KeyID, header.Algorithm)
+	}
+	var claims jwt.Claims
+	if err := tok.Claims(key.key, &claims); err != nil {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      j.issuer,
+		AnyAudience: jwt.Audience{j.audience},
+		Time:        j.now(),
+	}, 0)
+	switch {
+	case errors.Is(err, jwt.ErrInvalidIssuer):
+		return nil, fmt.Errorf("issuer must be %s, was %s", j.issuer, claims.Issuer)
+	case errors.Is(err, jwt.ErrInvalidAudience):
+		return nil, fmt.Errorf("audience must contain %s", j.audience)
+	case errors.Is(err, jwt.ErrExpired):
+		return nil, fmt.Errorf("token is expired")
+	case errors.Is(err, jwt.ErrNotValidYet):
+		return nil, fmt.Errorf("token is not yet valid")
+	case err != nil:
+		return nil, err
+	}
+	// Expiry is optional in JWTs, but required here, and a token expires at exp rather than after it
+	if claims.Expiry == nil || !j.now().Before(claims.Expiry.Time()) {
+		return nil, fmt.Errorf("token is expired")
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token missing subject")
+	}
+	return &claims, nil
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding.EncodeToString
+
+// signJWT returns a compact JWS over the claims, signed with the key
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	return signJWS(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}, key, claims)
+}
+
+// signJWS returns a compact JWS with the header over the claims, signed with the key. An HMAC
+// key is used for HS256, and a nil key leaves the signature empty.
+func signJWS(t *testing.T, headerFields map[string]string, key any, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(headerFields)
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatalf("error signing JWT: %v", err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The EC key doesn't declare its algorithm, which is then implied by its key type
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "OKP", "kid": "ed", "alg": "EdDSA", "crv": "Ed25519", "x": %q}
+	]}`,
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		b64(edKey.Public().(ed25519.PublicKey)))
+	a, err := NewJWTAuthenticator(writeFile(t, "jwks.json", jwks), "https://issuer.example.com", "bt-log")
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+
+	claims := func(modify func(c map[string]any)) map[string]any {
+		c := map[string]any{
+			"iss": "https://issuer.example.com",
+			"sub": "repo:example/my-package",
+			"aud": "bt-log",
+			"exp": now.Add(time.Minute).Unix(),
+			"iat": now.Unix(),
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantErrMsg string
+	}{
+		{name: "RS256", token: signJWT(t, "RS256", "rsa", rsaKey, claims(nil))},
+		{name: "ES256", token: signJWT(t, "ES256", "ec", ecKey, claims(nil))},
+		{name: "EdDSA", token: signJWT(t, "EdDSA", "ed", edKey, claims(nil))},
+		{
+			name:  "Audience list",
+			token: signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { c["aud"] = []string{"other", "bt-log"} })),
+		},
+		{
+			name:       "Unknown key ID",
+			token:      signJWT(t, "EdDSA", "unknown", edKey, claims(nil)),
+			wantErrMsg: `unknown key ID "unknown"`,
+		},
+		{
+			name:       "Wrong key",
+			token:      signJWT(t, "EdDSA", "ed", otherKey, claims(nil)),
+			wantErrMsg: "invalid signature",
+		},
+		{name: "No type", token: signJWS(t, map[string]string{"alg": "EdDSA", "kid": "ed"}, edKey, claims(nil))},
+		{name: "Lowercase type", token: signJWS(t, map[string]string{"alg": "EdDSA", "kid": "ed", "typ": "jwt"}, edKey, claims(nil))},
+		{
+			name:       "Other type",
+			token:      signJWS(t, map[string]string{"alg": "EdDSA", "kid": "ed", "typ": "at+jwt"}, edKey, claims(nil)),
+			wantErrMsg: "token type must be JWT, was at+jwt",
+		},
+		{
+			name:       "Algorithm not declared on key",
+			token:      signJWT(t, "ES256", "rsa", ecKey, claims(nil)),
+			wantErrMsg: `algorithm must be RS256 for key "rsa", was ES256`,
+		},
+		{
+			name:       "Algorithm doesn't match key type",
+			token:      signJWT(t, "EdDSA", "ec", edKey, claims(nil)),
+			wantErrMsg: `algorithm must be ES256 for key "ec", was EdDSA`,
+		},
+		{
+			// An HMAC over the token, keyed with the RSA public key, for a verifier that would
+			// use the key as an HMAC secret
+			name:       "HMAC with public key",
+			token:      signJWS(t, map[string]string{"alg": "HS256", "kid": "rsa", "typ": "JWT"}, x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), claims(nil)),
+			wantErrMsg: `unexpected signature algorithm "HS256"`,
+		},
+		{
+			name:       "No signature",
+			token:      signJWS(t, map[string]string{"alg": "none", "kid": "ed", "typ": "JWT"}, nil, claims(nil)),
+			wantErrMsg: `unexpected signature algorithm "none"`,
+		},
+		{
+			name:       "Only the header of a JWT",
+			token:      b64([]byte(`{"alg":"EdDSA","kid":"ed"}`)) + ".not-claims.not-a-signature",
+			wantErrMsg: "invalid signature",
+		},
+		{
+			name:       "Wrong issuer",
+			token:      signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { c["iss"] = "https://other.example.com" })),
+			wantErrMsg: "issuer must be https://issuer.example.com",
+		},
+		{
+			name:       "Wrong audience",
+			token:      signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { c["aud"] = "other" })),
+			wantErrMsg: "audience must contain bt-log",
+		},
+		{
+			name:       "Expired",
+			token:      signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { c["exp"] = now.Unix() })),
+			wantErrMsg: "token is expired",
+		},
+		{
+			name:       "Missing expiry",
+			token:      signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { delete(c, "exp") })),
+			wantErrMsg: "token is expired",
+		},
+		{
+			name:       "Not yet valid",
+			token:      signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { c["nbf"] = now.Add(time.Minute).Unix() })),
+			wantErrMsg: "token is not yet valid",
+		},
+		{
+			name:       "Missing subject",
+			token:      signJWT(t, "EdDSA", "ed", edKey, claims(func(c map[string]any) { delete(c, "sub") })),
+			wantErrMsg: "token missing subject",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/add", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			identity, err := a.Authenticate(r)
+			if tt.wantErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Errorf("Authenticate() error = %v, should contain %v", err, tt.wantErrMsg)
+				}
+				return
+			}
+			if want := "oidc:https://issuer.example.com/repo:example/my-package"; err != nil || identity != want {
+				t.Errorf("Authenticate() = %s, %v, want %s", identity, err, want)
+			}
+		})
+	}
+
+	// Tampering with the claims invalidates the signature
+	parts := strings.Split(signJWT(t, "EdDSA", "ed", edKey, claims(nil)), ".")
+	payload, _ := json.Marshal(claims(func(c map[string]any) { c["sub"] = "mallory" }))
+	r := httptest.NewRequest("POST", "/add", nil)
+	r.Header.Set("Authorization", "Bearer "+parts[0]+"."+b64(payload)+"."+parts[2])
+	if _, err := a.Authenticate(r); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Errorf("Authenticate() with modified claims error = %v, want invalid signature", err)
+	}
+
+	// Tokens that aren't a JWS with an algorithm are left to other authenticators
+	for _, token := range []string{
+		"a.b.c",
+		"alice.token.1",
+		b64([]byte(`{"typ":"JWT"}`)) + ".b.c",
+		b64([]byte(`["alg"]`)) + ".b.c",
+		b64([]byte(`{"alg":"EdDSA"}`)) + ".b",
+	} {
+		r := httptest.NewRequest("POST", "/add", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		if _, err := a.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("Authenticate(%s) error = %v, want %v", token, err, ErrNoCredentials)
+		}
+	}
+}
+
+func TestNewJWTAuthenticatorInvalid(t *testing.T) {
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, jwks := range []string{
+		`{"keys": [{"kty": "RSA", "kid": "rsa", "n": "` + b64(weakKey.N.Bytes()) + `", "e": "AQAB"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "rsa", "n": "` + b64(make([]byte, 256)) + `", "e": "AQAB"}]}`,
+		`{"keys": []}`,
+		`{"keys": [{"kty": "oct", "kid": "hmac"}]}`,
+		`{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-384", "x": "", "y": ""}]}`,
+		`{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "` + b64(make([]byte, 32)) + `", "y": "` + b64(make([]byte, 32)) + `"}]}`,
+		`{"keys": [{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AAAA"}]}`,
+		`{"keys": [{"kty": "OKP", "kid": "ed", "alg": "RS256", "crv": "Ed25519", "x": "` + b64(make([]byte, 32)) + `"}]}`,
+		`{"keys": [{"kty": "OKP", "kid": "ed", "alg": "HS256", "crv": "Ed25519", "x": "` + b64(make([]byte, 32)) + `"}]}`,
+		`{"keys": [{"kty": "OKP", "kid": "ed", "use": "enc", "crv": "Ed25519", "x": "` + b64(make([]byte, 32)) + `"}]}`,
+		`not json`,
+	} {
+		if _, err := NewJWTAuthenticator(writeFile(t, "jwks.json", jwks), "issuer", "audience"); err == nil {
+			t.Errorf("NewJWTAuthenticator(%s) expected error, got nil", jwks)
+		}
+	}
+}
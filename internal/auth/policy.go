@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// Policy limits which packages each submitter identity may log
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule allows an identity to log packages matching any of its patterns. Patterns are
+// matched against pkg:{type}/{namespace}/{name}, or pkg:{type}/{name} without a namespace,
+// using path.Match syntax, e.g. pkg:npm/@scope/* or pkg:pypi/my-package. The namespace and
+// name are not percent-encoded.
+type PolicyRule struct {
+	Identity string   `json:"identity"`
+	Packages []string `json:"packages"`
+}
+
+// LoadPolicy reads a JSON policy file
+func LoadPolicy(p string) (*Policy, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	for i, r := range policy.Rules {
+		if r.Identity == "" {
+			return nil, fmt.Errorf("policy rule %d missing identity", i)
+		}
+		for _, pattern := range r.Packages {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy rule %d has invalid pattern %s: %w", i, pattern, err)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Allowed returns true if the identity may log the package. A nil Policy allows all packages.
+func (p *Policy) Allowed(identity, purlType, namespace, name string) bool {
+	if p == nil {
+		return true
+	}
+	pkg := "pkg:" + purlType + "/" + name
+	if namespace != "" {
+		pkg = "pkg:" + purlType + "/" + namespace + "/" + name
+	}
+	for _, r := range p.Rules {
+		if r.Identity != identity {
+			continue
+		}
+		for _, pattern := range r.Packages {
+			if ok, _ := path.Match(pattern, pkg); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
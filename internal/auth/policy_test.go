@@ -0,0 +1,56 @@
+package auth
+
+import "testing"
+
+func TestPolicy(t *testing.T) {
+	p, err := LoadPolicy(writeFile(t, "policy.json", `{
+		"rules": [
+			{"identity": "token:alice", "packages": ["pkg:npm/@alice/*", "pkg:pypi/alice-utils"]},
+			{"identity": "cert:maven-ci", "packages": ["pkg:maven/org.example/*"]},
+			{"identity": "token:alice", "packages": ["pkg:cargo/*"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name                             string
+		identity, purlType, namespace, n string
+		want                             bool
+	}{
+		{name: "Scoped package", identity: "token:alice", purlType: "npm", namespace: "@alice", n: "my-package", want: true},
+		{name: "Other scope", identity: "token:alice", purlType: "npm", namespace: "@bob", n: "my-package", want: false},
+		{name: "Unscoped package", identity: "token:alice", purlType: "npm", n: "my-package", want: false},
+		{name: "Exact package", identity: "token:alice", purlType: "pypi", n: "alice-utils", want: true},
+		{name: "Exact package prefix", identity: "token:alice", purlType: "pypi", n: "alice-utils-extra", want: false},
+		{name: "Second rule for identity", identity: "token:alice", purlType: "cargo", n: "my-crate", want: true},
+		{name: "Namespace with name", identity: "cert:maven-ci", purlType: "maven", namespace: "org.example", n: "lib", want: true},
+		{name: "Other namespace", identity: "cert:maven-ci", purlType: "maven", namespace: "org.other", n: "lib", want: false},
+		{name: "Unknown identity", identity: "token:mallory", purlType: "cargo", n: "my-crate", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Allowed(tt.identity, tt.purlType, tt.namespace, tt.n); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var nilPolicy *Policy
+	if !nilPolicy.Allowed("anyone", "npm", "", "my-package") {
+		t.Errorf("Allowed() for nil policy = false, want true")
+	}
+}
+
+func TestLoadPolicyInvalid(t *testing.T) {
+	for _, policy := range []string{
+		`{"rules": [{"packages": ["pkg:npm/*"]}]}`,
+		`{"rules": [{"identity": "token:alice", "packages": ["pkg:npm/["]}]}`,
+		`not json`,
+	} {
+		if _, err := LoadPolicy(writeFile(t, "policy.json", policy)); err == nil {
+			t.Errorf("LoadPolicy(%s) expected error, got nil", policy)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// minCheckpointInterval is the smallest checkpoint interval the POSIX storage backend accepts,
+// which is the smallest of any backend
+const minCheckpointInterval = 100 * time.Millisecond
+
+// minBackendCheckpointInterval is the smallest checkpoint interval of each storage backend that
+// requires a longer interval than minCheckpointInterval
+var minBackendCheckpointInterval = map[string]time.Duration{
+	"mysql": time.Second,
+	"s3":    time.Second,
+}
+
+// Config tunes how the log sequences, deduplicates and publishes entries
+type Config struct {
+	// CheckpointInterval is how often a new checkpoint is signed and published. Shorter intervals
+	// lower the latency of /add, while longer intervals reduce witness and storage load.
+	CheckpointInterval Duration `json:"checkpoint_interval"`
+	Batching           Batching `json:"batching"`
+	Antispam           Antispam `json:"antispam"`
+}
+
+// Batching configures how entries are grouped before they're sequenced. A batch is sequenced once
+// it has MaxSize entries, or its oldest entry is MaxAge old, whichever is first. Larger batches
+// increase throughput, while shorter ages lower latency.
+type Batching struct {
+	MaxSize uint     `json:"max_size"`
+	MaxAge  Duration `json:"max_age"`
+}
+
+// Antispam configures deduplication of entries. Recently added entries are deduplicated in memory.
+// If PersistentPath or PersistentDSN is set, all entries are also deduplicated with a persistent
+// index that survives restarts, so resubmitting an entry returns its original index.
+type Antispam struct {
+	// InMemoryEntries is the number of recent entries deduplicated in memory
+	InMemoryEntries uint `json:"in_memory_entries"`
+	// PersistentPath is an optional directory for the persistent index. It must be local to a single
+	// log instance.
+	PersistentPath string `json:"persistent_path,omitempty"`
+	// PersistentDSN is an optional MySQL data source name for the persistent index, instead of
+	// PersistentPath. It may be shared by log instances, and may be the log's own database.
+	PersistentDSN string `json:"persistent_dsn,omitempty"`
+	// PushbackThreshold is how many entries the persistent index may fall behind the log before
+	// new entries are rejected. If zero, Tessera's default is used.
+	PushbackThreshold uint `json:"pushback_threshold,omitempty"`
+}
+
+// Duration is a time.Duration encoded in JSON as a string, e.g. "1.5s"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string, e.g. \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default returns the configuration used when no configuration file is provided
+func Default() Config {
+	return Config{
+		CheckpointInterval: Duration{5 * time.Second},
+		Batching: Batching{
+			MaxSize: 256,
+			MaxAge:  Duration{time.Second},
+		},
+		Antispam: Antispam{
+			InMemoryEntries: 256,
+		},
+	}
+}
+
+// Load reads a JSON configuration file. Fields not set in the file keep their default values.
+func Load(p string) (Config, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	c := Default()
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// Validate returns an error if the configuration can't be used by the log
+func (c Config) Validate() error {
+	if c.CheckpointInterval.Duration < minCheckpointInterval {
+		return fmt.Errorf("checkpoint_interval must be at least %v", minCheckpointInterval)
+	}
+	if c.Batching.MaxSize == 0 {
+		return fmt.Errorf("batching.max_size must be positive")
+	}
+	if c.Batching.MaxAge.Duration <= 0 {
+		return fmt.Errorf("batching.max_age must be positive")
+	}
+	if c.Antispam.InMemoryEntries == 0 {
+		return fmt.Errorf("antispam.in_memory_entries must be positive")
+	}
+	if c.Antispam.PersistentPath != "" && c.Antispam.PersistentDSN != "" {
+		return fmt.Errorf("antispam.persistent_path and antispam.persistent_dsn can't both be set")
+	}
+	if c.Antispam.PushbackThreshold != 0 && c.Antispam.PersistentPath == "" && c.Antispam.PersistentDSN == "" {
+		return fmt.Errorf("antispam.pushback_threshold requires antispam.persistent_path or antispam.persistent_dsn")
+	}
+	return nil
+}
+
+// ValidateBackend returns an error if the configuration can't be used with the storage backend,
+// in addition to the checks of Validate
+func (c Config) ValidateBackend(backend string) error {
+	if minInterval, ok := minBackendCheckpointInterval[backend]; ok && c.CheckpointInterval.Duration < minInterval {
+		return fmt.Errorf("checkpoint_interval must be at least %v for the %s storage backend", minInterval, backend)
+	}
+	// A local antispam index can't be shared by log instances, so it's only used with local storage
+	if c.Antispam.PersistentPath != "" && backend != "posix" {
+		return fmt.Errorf("antispam.persistent_path requires the posix storage backend, use antispam.persistent_dsn with the %s storage backend", backend)
+	}
+	return nil
+}
+
+// MaxSequencingDelay is the longest an entry may wait to be published in a checkpoint, excluding
+// the time taken to integrate and witness it
+func (c Config) MaxSequencingDelay() time.Duration {
+	return c.Batching.MaxAge.Duration + c.CheckpointInterval.Duration
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(p, []byte(contents), 0o600); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(writeConfig(t, `{
+		"checkpoint_interval": "500ms",
+		"batching": {"max_size": 1024},
+		"antispam": {"persistent_path": "/var/lib/bt-log/antispam", "pushback_threshold": 4096}
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Config{
+		CheckpointInterval: Duration{500 * time.Millisecond},
+		// max_age isn't set, so keeps its default
+		Batching: Batching{MaxSize: 1024, MaxAge: Duration{time.Second}},
+		Antispam: Antispam{InMemoryEntries: 256, PersistentPath: "/var/lib/bt-log/antispam", PushbackThreshold: 4096},
+	}
+	if c != want {
+		t.Errorf("Load() = %+v, want %+v", c, want)
+	}
+	if got := c.MaxSequencingDelay(); got != 1500*time.Millisecond {
+		t.Errorf("MaxSequencingDelay() = %v, want 1.5s", got)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	c, err := Load(writeConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c != Default() {
+		t.Errorf("Load() = %+v, want defaults %+v", c, Default())
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, contents := range map[string]string{
+		"malformed JSON":                    `{`,
+		"unknown field":                     `{"checkpoint_intervl": "5s"}`,
+		"numeric duration":                  `{"checkpoint_interval": 5}`,
+		"invalid duration":                  `{"checkpoint_interval": "5 seconds"}`,
+		"short checkpoint interval":         `{"checkpoint_interval": "10ms"}`,
+		"zero batch size":                   `{"batching": {"max_size": 0}}`,
+		"zero batch age":                    `{"batching": {"max_age": "0s"}}`,
+		"negative batch age":                `{"batching": {"max_age": "-1s"}}`,
+		"zero in-memory antispam entries":   `{"antispam": {"in_memory_entries": 0}}`,
+		"pushback without persistent index": `{"antispam": {"pushback_threshold": 10}}`,
+		"persistent path and DSN":           `{"antispam": {"persistent_path": "/var/lib/bt-log/antispam", "persistent_dsn": "user@/btlog"}}`,
+	} {
+		if _, err := Load(writeConfig(t, contents)); err == nil {
+			t.Errorf("Load() with %s expected error, got nil", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Load() with missing file expected error, got nil")
+	}
+}
+
+func TestValidateBackend(t *testing.T) {
+	c := Default()
+	c.CheckpointInterval = Duration{500 * time.Millisecond}
+	c.Antispam.PersistentPath = "/var/lib/bt-log/antispam"
+	if err := c.ValidateBackend("posix"); err != nil {
+		t.Errorf("ValidateBackend(posix) error = %v", err)
+	}
+	// The MySQL and S3 backends require longer checkpoint intervals, and a shared antispam index
+	for _, backend := range []string{"mysql", "s3"} {
+		if err := c.ValidateBackend(backend); err == nil {
+			t.Errorf("ValidateBackend(%s) with short checkpoint interval expected error, got nil", backend)
+		}
+		c.CheckpointInterval = Duration{time.Second}
+		if err := c.ValidateBackend(backend); err == nil {
+			t.Errorf("ValidateBackend(%s) with persistent_path expected error, got nil", backend)
+		}
+		c.Antispam.PersistentPath, c.Antispam.PersistentDSN = "", "user@/btlog"
+		if err := c.ValidateBackend(backend); err != nil {
+			t.Errorf("ValidateBackend(%s) error = %v", backend, err)
+		}
+		c.CheckpointInterval = Duration{500 * time.Millisecond}
+		c.Antispam.PersistentPath, c.Antispam.PersistentDSN = "/var/lib/bt-log/antispam", ""
+	}
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/haydentherapper/bt-log/internal/db/postgres"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	// ErrNotFound is returned when the witness doesn't track a log with the origin
+	ErrNotFound = errors.New("origin not known by witness")
+	// ErrExists is returned when adding a log the witness already tracks
+	ErrExists = errors.New("origin already tracked")
+	// ErrConflict is returned when a compare-and-swap fails because the log was updated
+	// concurrently
+	ErrConflict = errors.New("log was updated concurrently")
+)
+
+// Log is a log tracked by the witness, with the latest checkpoint the witness verified
+type Log struct {
+	Origin string
+	// PublicKey is the log's note verifier key
+	PublicKey string
+	TreeSize  uint64
+	TreeHash  []byte
+	// Checkpoint is the latest cosigned checkpoint note, which is nil until the witness has
+	// cosigned a checkpoint for the log
+	Checkpoint []byte
+}
+
+// Checkpoint is a checkpoint verified by the witness, with its size, root hash and cosigned note
+type Checkpoint struct {
+	Size uint64
+	Hash []byte
+	Note []byte
+}
+
+// WitnessStore persists the logs a witness tracks and the latest checkpoint verified for each
+type WitnessStore interface {
+	// GetLog returns the log with the origin, or ErrNotFound
+	GetLog(ctx context.Context, origin string) (*Log, error)
+	// ListLogs returns all tracked logs, ordered by origin
+	ListLogs(ctx context.Context) ([]Log, error)
+	// AddLog tracks a log whose checkpoints are verified starting from an empty tree, or
+	// returns ErrExists
+	AddLog(ctx context.Context, origin, publicKey string) error
+	// CompareAndSwapCheckpoint stores the log's latest verified checkpoint if the log's verified
+	// size is still oldSize, or returns ErrConflict
+	CompareAndSwapCheckpoint(ctx context.Context, origin string, oldSize uint64, cp Checkpoint) error
+	// CompareAndSwapPublicKey replaces the log's key if it's still oldKey, or returns ErrConflict
+	CompareAndSwapPublicKey(ctx context.Context, origin, oldKey, newKey string) error
+	// SetPublicKey replaces the log's key, keeping its verified checkpoint
+	SetPublicKey(ctx context.Context, origin, publicKey string) error
+	// DeleteLog stops tracking the log
+	DeleteLog(ctx context.Context, origin string) error
+	Close() error
+}
+
+// dialect is how a database type is opened, and how its queries are written
+type dialect struct {
+	driverName string
+	// rebind rewrites a query with '?' placeholders for the database
+	rebind func(string) string
+	// columnQuery counts the columns of a table with a name, given the table and column names
+	columnQuery string
+	// lock optionally serializes migrations by witnesses sharing the database, returning a
+	// function that releases the lock
+	lock func(ctx context.Context, conn *sql.Conn) (func(), error)
+}
+
+func noRebind(q string) string { return q }
+
+var dialects = map[string]dialect{
+	"sqlite": {
+		driverName:  "sqlite",
+		rebind:      noRebind,
+		columnQuery: "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+	},
+	"mysql": {
+		driverName:  "mysql",
+		rebind:      noRebind,
+		columnQuery: "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+		lock:        mysqlLock,
+	},
+	"postgres": {
+		driverName:  "pgx",
+		rebind:      postgres.Rebind,
+		columnQuery: "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?",
+		lock:        postgresLock,
+	},
+}
+
+// Open opens a witness database of the given type (sqlite, mysql or postgres) and applies any
+// pending schema migrations. A sqlite database can be opened by path rather than DSN.
+func Open(dbType, path, dsn string) (WitnessStore, error) {
+	s, _, err := OpenAndMigrate(context.Background(), dbType, path, dsn)
+	return s, err
+}
+
+// OpenAndMigrate opens a witness database like Open, also returning the migrations that were
+// applied, which are none if another witness sharing the database applied them first
+func OpenAndMigrate(ctx context.Context, dbType, path, dsn string) (WitnessStore, []Migration, error) {
+	db, d, err := openDB(dbType, path, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := migrate(ctx, db, d, false)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return &sqlStore{db: db, d: d}, applied, nil
+}
+
+func openDB(dbType, path, dsn string) (*sql.DB, dialect, error) {
+	d, ok := dialects[dbType]
+	if !ok {
+		return nil, d, fmt.Errorf("unsupported database type: %s. Must be one of 'sqlite', 'mysql', 'postgres'", dbType)
+	}
+	if (path == "") == (dsn == "") {
+		return nil, d, fmt.Errorf("exactly one of a database path or DSN must be set")
+	}
+	if path != "" {
+		if dbType != "sqlite" {
+			return nil, d, fmt.Errorf("a database path can only be used with sqlite")
+		}
+		// Enable Write-Ahead Logging for better concurrency, allowing reads during writes.
+		// A busy timeout is also set to prevent "database is locked" errors under contention,
+		// with writers waiting 1s before returning an error. Transactions take the write lock
+		// when they begin, so that concurrent transactions wait rather than fail when writing
+		// after reading, e.g. witnesses applying migrations at the same time. The sqlite driver
+		// only sets pragmas passed as _pragma parameters.
+		dsn = fmt.Sprintf("file:%s?_pragma=busy_timeout(1000)&_pragma=journal_mode(WAL)&_txlock=immediate", path)
+	}
+	db, err := sql.Open(d.driverName, dsn)
+	if err != nil {
+		return nil, d, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, d, nil
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testWitnessStore is the conformance suite that each WitnessStore implementation must pass.
+// The store must be empty.
+func testWitnessStore(t *testing.T, s WitnessStore) {
+	ctx := context.Background()
+	emptyRoot := sha256.Sum256([]byte{})
+
+	if logs, err := s.ListLogs(ctx); err != nil || len(logs) != 0 {
+		t.Fatalf("ListLogs() = %v, %v, want no logs", logs, err)
+	}
+	if _, err := s.GetLog(ctx, "b.example"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLog() for unknown origin error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.AddLog(ctx, "b.example", "b.example+1234+AAAA"); err != nil {
+		t.Fatalf("AddLog() error = %v", err)
+	}
+	if err := s.AddLog(ctx, "a.example", "a.example+1234+AAAA"); err != nil {
+		t.Fatalf("AddLog() error = %v", err)
+	}
+	if err := s.AddLog(ctx, "a.example", "a.example+5678+BBBB"); !errors.Is(err, ErrExists) {
+		t.Errorf("AddLog() for existing origin error = %v, want ErrExists", err)
+	}
+
+	want := Log{Origin: "b.example", PublicKey: "b.example+1234+AAAA", TreeHash: emptyRoot[:]}
+	if got, err := s.GetLog(ctx, "b.example"); err != nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetLog() = %+v, %v, want %+v", got, err, want)
+	}
+	logs, err := s.ListLogs(ctx)
+	if err != nil {
+		t.Fatalf("ListLogs() error = %v", err)
+	}
+	if len(logs) != 2 || logs[0].Origin != "a.example" || logs[1].Origin != "b.example" {
+		t.Errorf("ListLogs() = %+v, want logs ordered by origin", logs)
+	}
+
+	// Checkpoints are only stored when the verified size matches
+	cp := Checkpoint{Size: 10, Hash: []byte("hash10"), Note: []byte("b.example\n10\n")}
+	if err := s.CompareAndSwapCheckpoint(ctx, "b.example", 0, cp); err != nil {
+		t.Fatalf("CompareAndSwapCheckpoint() error = %v", err)
+	}
+	if err := s.CompareAndSwapCheckpoint(ctx, "b.example", 0, Checkpoint{Size: 5, Hash: []byte("hash5"), Note: []byte("5")}); !errors.Is(err, ErrConflict) {
+		t.Errorf("CompareAndSwapCheckpoint() with stale size error = %v, want ErrConflict", err)
+	}
+	// Storing the same checkpoint again isn't a conflict
+	if err := s.CompareAndSwapCheckpoint(ctx, "b.example", 10, cp); err != nil {
+		t.Errorf("CompareAndSwapCheckpoint() with identical checkpoint error = %v", err)
+	}
+	// Recosigning a checkpoint of the same size replaces the stored note
+	cp.Note = []byte("b.example\n10\n\nrecosigned")
+	if err := s.CompareAndSwapCheckpoint(ctx, "b.example", 10, cp); err != nil {
+		t.Errorf("CompareAndSwapCheckpoint() with same size error = %v", err)
+	}
+	if err := s.CompareAndSwapCheckpoint(ctx, "c.example", 0, cp); err == nil {
+		t.Errorf("CompareAndSwapCheckpoint() for unknown origin expected error, got nil")
+	}
+	want = Log{Origin: "b.example", PublicKey: "b.example+1234+AAAA", TreeSize: 10, TreeHash: cp.Hash, Checkpoint: cp.Note}
+	if got, err := s.GetLog(ctx, "b.example"); err != nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetLog() = %+v, %v, want %+v", got, err, want)
+	}
+
+	// Keys are only swapped when the current key matches
+	if err := s.CompareAndSwapPublicKey(ctx, "b.example", "b.example+9999+CCCC", "b.example+5678+BBBB"); !errors.Is(err, ErrConflict) {
+		t.Errorf("CompareAndSwapPublicKey() with stale key error = %v, want ErrConflict", err)
+	}
+	if err := s.CompareAndSwapPublicKey(ctx, "b.example", "b.example+1234+AAAA", "b.example+5678+BBBB"); err != nil {
+		t.Fatalf("CompareAndSwapPublicKey() error = %v", err)
+	}
+	// Swapping to the current key isn't a conflict
+	if err := s.CompareAndSwapPublicKey(ctx, "b.example", "b.example+5678+BBBB", "b.example+5678+BBBB"); err != nil {
+		t.Errorf("CompareAndSwapPublicKey() with identical key error = %v", err)
+	}
+	if err := s.SetPublicKey(ctx, "b.example", "b.example+9999+CCCC"); err != nil {
+		t.Fatalf("SetPublicKey() error = %v", err)
+	}
+	if err := s.SetPublicKey(ctx, "b.example", "b.example+9999+CCCC"); err != nil {
+		t.Errorf("SetPublicKey() with identical key error = %v", err)
+	}
+	if err := s.SetPublicKey(ctx, "c.example", "c.example+9999+CCCC"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetPublicKey() for unknown origin error = %v, want ErrNotFound", err)
+	}
+	// Replacing a key keeps the verified checkpoint
+	want.PublicKey = "b.example+9999+CCCC"
+	if got, err := s.GetLog(ctx, "b.example"); err != nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetLog() = %+v, %v, want %+v", got, err, want)
+	}
+
+	if err := s.DeleteLog(ctx, "b.example"); err != nil {
+		t.Fatalf("DeleteLog() error = %v", err)
+	}
+	if err := s.DeleteLog(ctx, "b.example"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteLog() for unknown origin error = %v, want ErrNotFound", err)
+	}
+	if logs, err := s.ListLogs(ctx); err != nil || len(logs) != 1 {
+		t.Errorf("ListLogs() after delete = %+v, %v, want one log", logs, err)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	s, err := Open("sqlite", filepath.Join(t.TempDir(), "witness.db"), "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	testWitnessStore(t, s)
+}
+
+// testExternalStore runs the conformance suite against an empty database whose DSN is set in
+// the environment variable, or skips the test if it's unset
+func testExternalStore(t *testing.T, dbType, env string) {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s not set", env)
+	}
+	s, err := Open(dbType, "", dsn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	testWitnessStore(t, s)
+}
+
+func TestMySQL(t *testing.T) {
+	testExternalStore(t, "mysql", "WITNESS_TEST_MYSQL_DSN")
+}
+
+func TestPostgres(t *testing.T) {
+	testExternalStore(t, "postgres", "WITNESS_TEST_POSTGRES_DSN")
+}
+
+func TestOpenInvalid(t *testing.T) {
+	for name, args := range map[string][3]string{
+		"unknown type":        {"oracle", "", "dsn"},
+		"no path or DSN":      {"sqlite", "", ""},
+		"path and DSN":        {"sqlite", "witness.db", "dsn"},
+		"path without sqlite": {"mysql", "witness.db", ""},
+	} {
+		if _, err := Open(args[0], args[1], args[2]); err == nil {
+			t.Errorf("Open() with %s expected error, got nil", name)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Migration is a versioned change to the witness database schema
+type Migration struct {
+	Version     int
+	Description string
+	// Statement must be supported by sqlite, mysql and postgres
+	Statement string
+}
+
+// migrations are applied in order, and each applied version is recorded in the
+// schema_migrations table. Migrations must only be appended, never edited or reordered.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create tlog table",
+		Statement: `
+			CREATE TABLE tlog (
+					origin VARCHAR(255) PRIMARY KEY,
+					public_key TEXT NOT NULL, -- note verifier format
+					tree_size INTEGER NOT NULL,
+					tree_hash TEXT NOT NULL -- base64-encoded
+			)`,
+	},
+	{
+		Version:     2,
+		Description: "store latest cosigned checkpoint note",
+		Statement:   "ALTER TABLE tlog ADD COLUMN checkpoint TEXT",
+	},
+}
+
+// Migrate applies pending schema migrations to a witness database, returning the migrations
+// that were applied. If dryRun is set, the pending migrations are returned without being
+// applied.
+func Migrate(ctx context.Context, dbType, path, dsn string, dryRun bool) ([]Migration, error) {
+	db, d, err := openDB(dbType, path, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return migrate(ctx, db, d, dryRun)
+}
+
+// migrationLock is the name of the lock that serializes migrations
+const migrationLock = "witness_schema_migrations"
+
+// mysqlLock takes a named lock on the connection, waiting up to a minute for another witness's
+// migrations to finish
+func mysqlLock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", migrationLock).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		return nil, fmt.Errorf("timed out waiting for lock %s", migrationLock)
+	}
+	return func() {
+		var released sql.NullInt64
+		_ = conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLock).Scan(&released)
+	}, nil
+}
+
+// postgresLock takes a session advisory lock keyed by the hash of the lock name, waiting until
+// another witness's migrations finish
+func postgresLock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", migrationLock); err != nil {
+		return nil, err
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", migrationLock)
+	}, nil
+}
+
+// querier is a *sql.Conn or *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// migrate applies pending migrations. Witnesses sharing a database may start at the same time,
+// so migrations are serialized with a database lock where the database has one. Otherwise,
+// each migration's version is checked again in its transaction, and a migration that fails
+// because another witness applied it first is skipped.
+func migrate(ctx context.Context, db *sql.DB, d dialect, dryRun bool) ([]Migration, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+	if !dryRun && d.lock != nil {
+		unlock, err := d.lock(ctx, conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock database for migrations: %w", err)
+		}
+		defer unlock()
+	}
+
+	version, recorded, err := schemaVersion(ctx, conn, d)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+
+	if !recorded {
+		if err := recordVersion(ctx, conn, d, version); err != nil {
+			return nil, err
+		}
+	}
+	var applied []Migration
+	for _, m := range pending {
+		ok, err := applyMigration(ctx, conn, d, m)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration applies a migration with its version in a transaction, returning false if
+// it was already applied. MySQL commits schema changes implicitly, so a failed migration may
+// need to be repaired by hand.
+func applyMigration(ctx context.Context, conn *sql.Conn, d dialect, m Migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	// If the migration fails because another witness applied it first, it's skipped
+	fail := func(err error) (bool, error) {
+		_ = tx.Rollback()
+		if ok, checkErr := migrationApplied(ctx, conn, d, m.Version); checkErr == nil && ok {
+			return false, nil
+		}
+		return false, err
+	}
+	ok, err := migrationApplied(ctx, tx, d, m.Version)
+	if err != nil || ok {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, m.Statement); err != nil {
+		return fail(fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Description, err))
+	}
+	insertQuery := d.rebind("INSERT INTO schema_migrations (version, description) VALUES (?, ?)")
+	if _, err := tx.ExecContext(ctx, insertQuery, m.Version, m.Description); err != nil {
+		return fail(fmt.Errorf("failed to record migration %d: %w", m.Version, err))
+	}
+	if err := tx.Commit(); err != nil {
+		return fail(fmt.Errorf("failed to commit migration %d: %w", m.Version, err))
+	}
+	return true, nil
+}
+
+// migrationApplied reports whether the migration's version is recorded as applied
+func migrationApplied(ctx context.Context, q querier, d dialect, version int) (bool, error) {
+	var n int
+	if err := q.QueryRowContext(ctx, d.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), version).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return n > 0, nil
+}
+
+// hasColumn reports whether the table exists with the column
+func hasColumn(ctx context.Context, q querier, d dialect, table, column string) (bool, error) {
+	var n int
+	if err := q.QueryRowContext(ctx, d.rebind(d.columnQuery), table, column).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to read schema of %s: %w", table, err)
+	}
+	return n > 0, nil
+}
+
+// schemaVersion returns the latest migration applied to the database, and whether it's
+// recorded in the schema_migrations table. Databases created before migrations were recorded
+// are versioned by their schema.
+func schemaVersion(ctx context.Context, q querier, d dialect) (int, bool, error) {
+	ok, err := hasColumn(ctx, q, d, "schema_migrations", "version")
+	if err != nil {
+		return 0, false, err
+	}
+	if ok {
+		var version sql.NullInt64
+		if err := q.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+			return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+		}
+		return int(version.Int64), true, nil
+	}
+	for _, c := range []struct {
+		column  string
+		version int
+	}{{"checkpoint", 2}, {"origin", 1}} {
+		ok, err := hasColumn(ctx, q, d, "tlog", c.column)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			return c.version, false, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// recordVersion creates the schema_migrations table, recording the migrations up to the version
+// as applied, unless another witness has already recorded them
+func recordVersion(ctx context.Context, conn *sql.Conn, d dialect, version int) error {
+	if _, err := conn.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+					version INTEGER PRIMARY KEY,
+					description TEXT NOT NULL
+			)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		insertQuery := d.rebind("INSERT INTO schema_migrations (version, description) VALUES (?, ?)")
+		if _, err := conn.ExecContext(ctx, insertQuery, m.Version, m.Description); err != nil {
+			if ok, checkErr := migrationApplied(ctx, conn, d, m.Version); checkErr != nil || !ok {
+				return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func versions(ms []Migration) []int {
+	var vs []int
+	for _, m := range ms {
+		vs = append(vs, m.Version)
+	}
+	return vs
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "witness.db")
+
+	// A dry run doesn't apply migrations
+	for range 2 {
+		pending, err := Migrate(ctx, "sqlite", path, "", true)
+		if err != nil {
+			t.Fatalf("Migrate() dry run error = %v", err)
+		}
+		if len(pending) != len(migrations) {
+			t.Errorf("Migrate() dry run = %v, want all migrations pending", versions(pending))
+		}
+	}
+
+	applied, err := Migrate(ctx, "sqlite", path, "", false)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Migrate() = %v, want all migrations applied", versions(applied))
+	}
+	for _, dryRun := range []bool{true, false} {
+		if ms, err := Migrate(ctx, "sqlite", path, "", dryRun); err != nil || len(ms) != 0 {
+			t.Errorf("Migrate() after migrating = %v, %v, want no migrations", versions(ms), err)
+		}
+	}
+
+	s, err := Open("sqlite", path, "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if err := s.AddLog(ctx, "a.example", "a.example+1234+AAAA"); err != nil {
+		t.Errorf("AddLog() after migrating error = %v", err)
+	}
+}
+
+func TestOpenAndMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "witness.db")
+
+	// Migrations are applied once, when the database is first opened
+	for _, want := range []int{len(migrations), 0} {
+		s, applied, err := OpenAndMigrate(ctx, "sqlite", path, "")
+		if err != nil {
+			t.Fatalf("OpenAndMigrate() error = %v", err)
+		}
+		s.Close()
+		if len(applied) != want {
+			t.Errorf("OpenAndMigrate() applied %v, want %d migrations", versions(applied), want)
+		}
+	}
+}
+
+func TestMigrateUnversioned(t *testing.T) {
+	ctx := context.Background()
+	for name, tc := range map[string]struct {
+		schema  string
+		pending []int
+	}{
+		"without checkpoint": {
+			schema:  "CREATE TABLE tlog (origin VARCHAR(255) PRIMARY KEY, public_key TEXT NOT NULL, tree_size INTEGER NOT NULL, tree_hash TEXT NOT NULL)",
+			pending: []int{2},
+		},
+		"with checkpoint": {
+			schema: "CREATE TABLE tlog (origin VARCHAR(255) PRIMARY KEY, public_key TEXT NOT NULL, tree_size INTEGER NOT NULL, tree_hash TEXT NOT NULL, checkpoint TEXT)",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "witness.db")
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			if _, err := db.Exec(tc.schema); err != nil {
+				t.Fatalf("failed to create table: %v", err)
+			}
+			if _, err := db.Exec("INSERT INTO tlog (origin, public_key, tree_size, tree_hash) VALUES ('a.example', 'a.example+1234+AAAA', 5, '')"); err != nil {
+				t.Fatalf("failed to insert log: %v", err)
+			}
+			db.Close()
+
+			applied, err := Migrate(ctx, "sqlite", path, "", false)
+			if err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			if got := versions(applied); !reflect.DeepEqual(got, tc.pending) {
+				t.Errorf("Migrate() = %v, want %v", got, tc.pending)
+			}
+			if ms, err := Migrate(ctx, "sqlite", path, "", true); err != nil || len(ms) != 0 {
+				t.Errorf("Migrate() dry run after migrating = %v, %v, want no migrations", versions(ms), err)
+			}
+
+			// Existing logs are kept
+			s, err := Open("sqlite", path, "")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer s.Close()
+			if l, err := s.GetLog(ctx, "a.example"); err != nil || l.TreeSize != 5 {
+				t.Errorf("GetLog() after migrating = %+v, %v", l, err)
+			}
+		})
+	}
+}
+
+func TestMigrateConcurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "witness.db")
+
+	// Witnesses starting at the same time each apply or skip every migration
+	const witnesses = 8
+	results := make(chan []Migration, witnesses)
+	errs := make(chan error, witnesses)
+	for range witnesses {
+		go func() {
+			applied, err := Migrate(ctx, "sqlite", path, "", false)
+			results <- applied
+			errs <- err
+		}()
+	}
+	var applied []int
+	for range witnesses {
+		applied = append(applied, versions(<-results)...)
+		if err := <-errs; err != nil {
+			t.Errorf("Migrate() error = %v", err)
+		}
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Migrate() applied %v, want each migration applied once", applied)
+	}
+	if ms, err := Migrate(ctx, "sqlite", path, "", true); err != nil || len(ms) != 0 {
+		t.Errorf("Migrate() dry run after migrating = %v, %v, want no migrations", versions(ms), err)
+	}
+}
+
+func TestMigrateError(t *testing.T) {
+	db, d, err := openDB("sqlite", filepath.Join(t.TempDir(), "witness.db"), "")
+	if err != nil {
+		t.Fatalf("openDB() error = %v", err)
+	}
+	db.Close()
+
+	// Errors reading the schema aren't mistaken for a missing table
+	if _, err := migrate(context.Background(), db, d, true); err == nil {
+		t.Errorf("migrate() with closed database expected error, got nil")
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sqlStore is a WitnessStore backed by a SQL database. Each log is a row in the tlog table.
+type sqlStore struct {
+	db *sql.DB
+	d  dialect
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLog(row scanner) (*Log, error) {
+	var l Log
+	var treeHashB64 string
+	var checkpoint sql.NullString
+	if err := row.Scan(&l.Origin, &l.PublicKey, &l.TreeSize, &treeHashB64, &checkpoint); err != nil {
+		return nil, err
+	}
+	treeHash, err := base64.StdEncoding.DecodeString(treeHashB64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tree hash for %s: %w", l.Origin, err)
+	}
+	l.TreeHash = treeHash
+	if checkpoint.Valid {
+		l.Checkpoint = []byte(checkpoint.String)
+	}
+	return &l, nil
+}
+
+const selectLog = "SELECT origin, public_key, tree_size, tree_hash, checkpoint FROM tlog"
+
+func (s *sqlStore) GetLog(ctx context.Context, origin string) (*Log, error) {
+	l, err := scanLog(s.db.QueryRowContext(ctx, s.d.rebind(selectLog+" WHERE origin = ?"), origin))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return l, err
+}
+
+func (s *sqlStore) ListLogs(ctx context.Context) ([]Log, error) {
+	rows, err := s.db.QueryContext(ctx, selectLog+" ORDER BY origin")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	logs := []Log{}
+	for rows.Next() {
+		l, err := scanLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, *l)
+	}
+	return logs, rows.Err()
+}
+
+func (s *sqlStore) AddLog(ctx context.Context, origin, publicKey string) error {
+	// Check for an existing log, rather than relying on each database's primary key error
+	var count int
+	if err := s.db.QueryRowContext(ctx, s.d.rebind("SELECT COUNT(*) FROM tlog WHERE origin = ?"), origin).Scan(&count); err != nil {
+		return fmt.Errorf("failed to check for existing origin: %w", err)
+	}
+	if count > 0 {
+		return ErrExists
+	}
+	// root hash for empty merkle tree
+	emptyRoot := sha256.Sum256([]byte{})
+	insertQuery := s.d.rebind("INSERT INTO tlog (origin, public_key, tree_size, tree_hash) VALUES (?, ?, ?, ?)")
+	_, err := s.db.ExecContext(ctx, insertQuery, origin, publicKey, 0, base64.StdEncoding.EncodeToString(emptyRoot[:]))
+	return err
+}
+
+// updateOne runs an update that's expected to change the log's row. If no row changed, the
+// log is read back and passed to applied, which reports whether the log is already in the
+// updated state. MySQL doesn't count no-op updates as affected rows, so an update isn't
+// a conflict just because no row changed.
+func (s *sqlStore) updateOne(ctx context.Context, origin string, applied func(*Log) bool, query string, args ...any) error {
+	r, err := s.db.ExecContext(ctx, s.d.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	if c, err := r.RowsAffected(); err != nil {
+		return fmt.Errorf("error reading rows after update: %w", err)
+	} else if c == 1 {
+		return nil
+	}
+	l, err := s.GetLog(ctx, origin)
+	if err != nil {
+		return err
+	}
+	if !applied(l) {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *sqlStore) CompareAndSwapCheckpoint(ctx context.Context, origin string, oldSize uint64, cp Checkpoint) error {
+	// Only update where tree_size matches the last verified size, to prevent concurrent
+	// updates from rolling back the witness state
+	return s.updateOne(ctx, origin, func(l *Log) bool {
+		return l.TreeSize == cp.Size && bytes.Equal(l.TreeHash, cp.Hash) && bytes.Equal(l.Checkpoint, cp.Note)
+	}, "UPDATE tlog SET tree_size = ?, tree_hash = ?, checkpoint = ? WHERE origin = ? AND tree_size = ?",
+		cp.Size, base64.StdEncoding.EncodeToString(cp.Hash), string(cp.Note), origin, oldSize)
+}
+
+func (s *sqlStore) CompareAndSwapPublicKey(ctx context.Context, origin, oldKey, newKey string) error {
+	return s.updateOne(ctx, origin, func(l *Log) bool {
+		return strings.TrimSpace(l.PublicKey) == strings.TrimSpace(newKey)
+	}, "UPDATE tlog SET public_key = ? WHERE origin = ? AND public_key = ?", newKey, origin, oldKey)
+}
+
+func (s *sqlStore) SetPublicKey(ctx context.Context, origin, publicKey string) error {
+	return s.updateOne(ctx, origin, func(*Log) bool { return true },
+		"UPDATE tlog SET public_key = ? WHERE origin = ?", publicKey, origin)
+}
+
+func (s *sqlStore) DeleteLog(ctx context.Context, origin string) error {
+	r, err := s.db.ExecContext(ctx, s.d.rebind("DELETE FROM tlog WHERE origin = ?"), origin)
+	if err != nil {
+		return err
+	}
+	if c, err := r.RowsAffected(); err != nil {
+		return fmt.Errorf("error reading rows after delete: %w", err)
+	} else if c == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
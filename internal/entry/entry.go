@@ -0,0 +1,259 @@
+package entry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/haydentherapper/bt-log/internal/purl"
+	"github.com/package-url/packageurl-go"
+)
+
+// The first byte of every entry is its format version. Entries logged before the
+// v1 format are raw pURL strings, which always start with "pkg:".
+const (
+	version1 byte = 1
+	// version2 adds the publisher key ID
+	version2 byte = 2
+)
+
+// Entry is a log entry describing a single package artifact
+type Entry struct {
+	Type      string            `json:"type"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Digests   map[string]string `json:"digests"`             // Checksum algorithm to hex-encoded digest
+	Filename  string            `json:"filename,omitempty"`  // Artifact filename, e.g. pkgname-1.2.3.tar.gz
+	Publisher string            `json:"publisher,omitempty"` // Identity of the publisher that submitted the entry
+	// ID of the publisher key that signed the submission, from the log's publisher keyring
+	PublisherKeyID string `json:"publisherKeyId,omitempty"`
+}
+
+// FromPURL returns an entry for a pURL containing a checksum qualifier,
+// e.g. pkg:pypi/pkgname@1.2.3?checksum=sha256:5141b5b5...
+func FromPURL(purlString string) (*Entry, error) {
+	p, err := packageurl.FromString(purlString)
+	if err != nil {
+		return nil, err
+	}
+	checksum, ok := p.Qualifiers.Map()["checksum"]
+	if !ok {
+		return nil, fmt.Errorf("pURL missing checksum qualifier")
+	}
+	digests, err := purl.ParseChecksums(checksum, purl.SupportedChecksumAlgorithms())
+	if err != nil {
+		return nil, err
+	}
+	for alg, digest := range digests {
+		digests[alg] = strings.ToLower(digest)
+	}
+	return &Entry{
+		Type:      p.Type,
+		Namespace: p.Namespace,
+		Name:      p.Name,
+		Version:   p.Version,
+		Digests:   digests,
+	}, nil
+}
+
+// ID returns the package identity, which is the pURL without qualifiers, e.g. pkg:pypi/pkgname@1.2.3
+func (e Entry) ID() string {
+	return purl.PackageID(packageurl.PackageURL{Type: e.Type, Namespace: e.Namespace, Name: e.Name, Version: e.Version})
+}
+
+// Checksum returns the digests as a checksum qualifier value, sorted by algorithm,
+// e.g. sha256:5141b5b5...,sha512:8f1a2c3d...
+func (e Entry) Checksum() string {
+	var checksums []string
+	for _, alg := range e.algorithms() {
+		checksums = append(checksums, alg+":"+e.Digests[alg])
+	}
+	return strings.Join(checksums, ",")
+}
+
+// PURL returns the entry as a canonical pURL with a checksum qualifier, which is
+// percent-encoded, e.g. pkg:pypi/pkgname@1.2.3?checksum=sha256%3A5141b5b5...
+func (e Entry) PURL() string {
+	q := packageurl.QualifiersFromMap(map[string]string{"checksum": e.Checksum()})
+	return packageurl.NewPackageURL(e.Type, e.Namespace, e.Name, e.Version, q, "").ToString()
+}
+
+func (e Entry) algorithms() []string {
+	algs := make([]string, 0, len(e.Digests))
+	for alg := range e.Digests {
+		algs = append(algs, alg)
+	}
+	slices.Sort(algs)
+	return algs
+}
+
+func (e Entry) validate() error {
+	if e.Type == "" || e.Name == "" || e.Version == "" {
+		return fmt.Errorf("entry must contain type, name and version")
+	}
+	if len(e.Digests) == 0 {
+		return fmt.Errorf("entry must contain at least one digest")
+	}
+	if len(e.Digests) > math.MaxUint8 {
+		return fmt.Errorf("entry must contain at most %d digests", math.MaxUint8)
+	}
+	if _, err := purl.ParseChecksums(e.Checksum(), purl.SupportedChecksumAlgorithms()); err != nil {
+		return err
+	}
+	if e.Filename != "" && (e.Filename != path.Base(e.Filename) || e.Filename == "." || e.Filename == "..") {
+		return fmt.Errorf("entry filename must not contain a path, was %s", e.Filename)
+	}
+	return nil
+}
+
+// Marshal returns the canonical encoding of the entry. Each field is encoded as a
+// big-endian uint16 length followed by its bytes, with digests sorted by algorithm:
+//
+//	uint8 version
+//	type, namespace, name, version
+//	uint8 digest count, then algorithm and raw digest per digest
+//	filename, publisher
+//	publisher key ID, for version 2 only
+//
+// Entries without a publisher key ID are encoded as version 1, so that each entry has
+// exactly one encoding.
+func (e Entry) Marshal() ([]byte, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	version := version1
+	if e.PublisherKeyID != "" {
+		version = version2
+	}
+	b := []byte{version}
+	var err error
+	for _, s := range []string{e.Type, e.Namespace, e.Name, e.Version} {
+		if b, err = appendField(b, []byte(s)); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, uint8(len(e.Digests)))
+	for _, alg := range e.algorithms() {
+		// Digests are validated as hex-encoded above
+		digest, _ := hex.DecodeString(e.Digests[alg])
+		if b, err = appendField(b, []byte(alg)); err != nil {
+			return nil, err
+		}
+		if b, err = appendField(b, digest); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range []string{e.Filename, e.Publisher} {
+		if b, err = appendField(b, []byte(s)); err != nil {
+			return nil, err
+		}
+	}
+	if version == version2 {
+		if b, err = appendField(b, []byte(e.PublisherKeyID)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Unmarshal parses an entry from its canonical encoding, or from a raw pURL string
+// for entries logged before the v1 format
+func (e *Entry) Unmarshal(data []byte) error {
+	if bytes.HasPrefix(data, []byte("pkg:")) {
+		legacy, err := FromPURL(string(data))
+		if err != nil {
+			return err
+		}
+		*e = *legacy
+		return nil
+	}
+	if len(data) == 0 || (data[0] != version1 && data[0] != version2) {
+		return fmt.Errorf("unsupported entry format")
+	}
+	r := &reader{b: data[1:]}
+	parsed := Entry{
+		Type:      string(r.field()),
+		Namespace: string(r.field()),
+		Name:      string(r.field()),
+		Version:   string(r.field()),
+		Digests:   make(map[string]string),
+	}
+	count := int(r.byte())
+	for i := 0; i < count; i++ {
+		alg := string(r.field())
+		parsed.Digests[alg] = hex.EncodeToString(r.field())
+	}
+	parsed.Filename = string(r.field())
+	parsed.Publisher = string(r.field())
+	if data[0] == version2 {
+		parsed.PublisherKeyID = string(r.field())
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.b) != 0 {
+		return fmt.Errorf("entry contains %d trailing bytes", len(r.b))
+	}
+	// Reject any encoding that differs from the canonical encoding, such as unsorted or
+	// duplicate digests, so that an entry has exactly one leaf hash
+	canonical, err := parsed.Marshal()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(canonical, data) {
+		return fmt.Errorf("entry is not canonically encoded")
+	}
+	*e = parsed
+	return nil
+}
+
+func appendField(b, field []byte) ([]byte, error) {
+	if len(field) > math.MaxUint16 {
+		return nil, fmt.Errorf("entry field must be at most %d bytes, was %d", math.MaxUint16, len(field))
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(field)))
+	return append(b, field...), nil
+}
+
+// reader reads fields from an encoded entry, recording the first error
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 1 {
+		r.err = fmt.Errorf("entry is truncated")
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *reader) field() []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < 2 {
+		r.err = fmt.Errorf("entry is truncated")
+		return nil
+	}
+	n := int(binary.BigEndian.Uint16(r.b))
+	if len(r.b) < 2+n {
+		r.err = fmt.Errorf("entry is truncated")
+		return nil
+	}
+	v := r.b[2 : 2+n]
+	r.b = r.b[2+n:]
+	return v
+}
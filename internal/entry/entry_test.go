@@ -0,0 +1,221 @@
+package entry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	sha256Digest = "5141b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be92"
+	purlString   = "pkg:pypi/pkgname@1.2.3?checksum=sha256:" + sha256Digest
+)
+
+func TestFromPURL(t *testing.T) {
+	sha512Digest := strings.Repeat("ab", 64)
+	e, err := FromPURL("pkg:npm/%40scope/pkgname@1.2.3?checksum=sha512:" + strings.ToUpper(sha512Digest) + ",sha256:" + sha256Digest)
+	if err != nil {
+		t.Fatalf("FromPURL() error = %v", err)
+	}
+	want := &Entry{
+		Type:      "npm",
+		Namespace: "@scope",
+		Name:      "pkgname",
+		Version:   "1.2.3",
+		Digests:   map[string]string{"sha256": sha256Digest, "sha512": sha512Digest},
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("FromPURL() = %+v, want %+v", e, want)
+	}
+	if got, want := e.ID(), "pkg:npm/%40scope/pkgname@1.2.3"; got != want {
+		t.Errorf("ID() = %s, want %s", got, want)
+	}
+	if got, want := e.Checksum(), "sha256:"+sha256Digest+",sha512:"+sha512Digest; got != want {
+		t.Errorf("Checksum() = %s, want %s", got, want)
+	}
+
+	for _, invalid := range []string{
+		"pkg:pypi/pkgname@1.2.3",
+		"pkg:pypi/pkgname@1.2.3?checksum=sha256:abcd",
+		"pkg:pypi/pkgname@1.2.3?checksum=md5:" + strings.Repeat("ab", 16),
+		"not a purl",
+	} {
+		if _, err := FromPURL(invalid); err == nil {
+			t.Errorf("FromPURL(%s) expected error, got nil", invalid)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	e, err := FromPURL(purlString)
+	if err != nil {
+		t.Fatalf("FromPURL() error = %v", err)
+	}
+	e.Filename = "pkgname-1.2.3.tar.gz"
+	e.Publisher = "publisher@example.com"
+
+	data, err := e.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var got Entry
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(&got, e) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, e)
+	}
+	if want := strings.Replace(purlString, "sha256:", "sha256%3A", 1); got.PURL() != want {
+		t.Errorf("PURL() = %s, want %s", got.PURL(), want)
+	}
+
+	// Any change to the encoding must be rejected
+	for name, modified := range map[string][]byte{
+		"unknown version": append([]byte{3}, data[1:]...),
+		"wrong version":   append([]byte{2}, data[1:]...),
+		"truncated":       data[:len(data)-1],
+		"trailing bytes":  append(append([]byte{}, data...), 0),
+		"empty":           {},
+	} {
+		if err := new(Entry).Unmarshal(modified); err == nil {
+			t.Errorf("Unmarshal() with %s expected error, got nil", name)
+		}
+	}
+}
+
+func TestMarshalPublisherKeyID(t *testing.T) {
+	e, err := FromPURL(purlString)
+	if err != nil {
+		t.Fatalf("FromPURL() error = %v", err)
+	}
+	e.PublisherKeyID = "publisher-key"
+	data, err := e.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if data[0] != version2 {
+		t.Errorf("Marshal() version = %d, want %d", data[0], version2)
+	}
+	var got Entry
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(&got, e) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, e)
+	}
+
+	// An empty publisher key ID must be encoded as version 1
+	e.PublisherKeyID = ""
+	v1, err := e.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	v2 := append(append([]byte{version2}, v1[1:]...), 0, 0)
+	if err := new(Entry).Unmarshal(v2); err == nil || !strings.Contains(err.Error(), "not canonically encoded") {
+		t.Errorf("Unmarshal() of version 2 without key ID error = %v, want not canonically encoded", err)
+	}
+}
+
+func TestUnmarshalNonCanonical(t *testing.T) {
+	e := Entry{
+		Type:    "pypi",
+		Name:    "pkgname",
+		Version: "1.2.3",
+		Digests: map[string]string{"sha256": sha256Digest, "sha512": strings.Repeat("ab", 64)},
+	}
+	data, err := e.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	// Swap the order of the two digests, which are sorted in the canonical encoding
+	digestsStart := 1 + 2 + len("pypi") + 2 + 2 + len("pkgname") + 2 + len("1.2.3") + 1
+	sha256Len := 2 + len("sha256") + 2 + 32
+	sha512Len := 2 + len("sha512") + 2 + 64
+	var swapped []byte
+	swapped = append(swapped, data[:digestsStart]...)
+	swapped = append(swapped, data[digestsStart+sha256Len:digestsStart+sha256Len+sha512Len]...)
+	swapped = append(swapped, data[digestsStart:digestsStart+sha256Len]...)
+	swapped = append(swapped, data[digestsStart+sha256Len+sha512Len:]...)
+	if len(swapped) != len(data) {
+		t.Fatalf("swapped encoding has length %d, want %d", len(swapped), len(data))
+	}
+	if err := new(Entry).Unmarshal(swapped); err == nil || !strings.Contains(err.Error(), "not canonically encoded") {
+		t.Errorf("Unmarshal() with unsorted digests error = %v, want not canonically encoded", err)
+	}
+}
+
+func TestUnmarshalLegacy(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantID       string
+		wantChecksum string
+		wantErr      bool
+	}{
+		{
+			name:         "Valid entry",
+			data:         purlString,
+			wantID:       "pkg:pypi/pkgname@1.2.3",
+			wantChecksum: "sha256:" + sha256Digest,
+		},
+		{
+			name:         "Valid entry with namespace and encoded checksum",
+			data:         "pkg:maven/org.example/pkgname@1.2.3?checksum=sha256%3A" + sha256Digest,
+			wantID:       "pkg:maven/org.example/pkgname@1.2.3",
+			wantChecksum: "sha256:" + sha256Digest,
+		},
+		{
+			name:    "Invalid pURL",
+			data:    "pkg:",
+			wantErr: true,
+		},
+		{
+			name:    "Missing checksum",
+			data:    "pkg:pypi/pkgname@1.2.3",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Entry
+			err := e.Unmarshal([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if e.ID() != tt.wantID || e.Checksum() != tt.wantChecksum {
+				t.Errorf("Unmarshal() = %s, %s, want %s, %s", e.ID(), e.Checksum(), tt.wantID, tt.wantChecksum)
+			}
+		})
+	}
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	valid := func() Entry {
+		return Entry{Type: "pypi", Name: "pkgname", Version: "1.2.3", Digests: map[string]string{"sha256": sha256Digest}}
+	}
+	tests := []struct {
+		name   string
+		modify func(e *Entry)
+	}{
+		{name: "Missing name", modify: func(e *Entry) { e.Name = "" }},
+		{name: "Missing version", modify: func(e *Entry) { e.Version = "" }},
+		{name: "Missing digests", modify: func(e *Entry) { e.Digests = nil }},
+		{name: "Invalid digest length", modify: func(e *Entry) { e.Digests["sha256"] = "abcd" }},
+		{name: "Unsupported algorithm", modify: func(e *Entry) { e.Digests["md5"] = strings.Repeat("ab", 16) }},
+		{name: "Filename with path", modify: func(e *Entry) { e.Filename = "dist/pkgname-1.2.3.tar.gz" }},
+		{name: "Field too long", modify: func(e *Entry) { e.Publisher = strings.Repeat("a", 1<<16) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := valid()
+			tt.modify(&e)
+			if _, err := e.Marshal(); err == nil {
+				t.Errorf("Marshal() expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Package flags parses command-line flag values shared by the log's binaries
+package flags
+
+import "strings"
+
+// SplitList splits a comma-separated flag value, trimming spaces and ignoring empty values,
+// e.g. "sha256, sha512," is sha256 and sha512
+func SplitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
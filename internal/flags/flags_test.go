@@ -0,0 +1,21 @@
+package flags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	for s, want := range map[string][]string{
+		"":                  {},
+		"sha256":            {"sha256"},
+		"sha256,sha512":     {"sha256", "sha512"},
+		"sha256, sha512":    {"sha256", "sha512"},
+		" sha256 ,,sha512,": {"sha256", "sha512"},
+		" , ":               {},
+	} {
+		if got := SplitList(s); len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("SplitList(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/transparency-dev/merkle/rfc6962"
+	_ "modernc.org/sqlite"
+)
+
+// Entry is an indexed log entry
+type Entry struct {
+	Index    uint64
+	ID       string // Package identity, the pURL without checksum
+	Checksum string
+	Data     []byte // Raw log entry
+}
+
+// Package is the checksum recorded for a package identity
+type Package struct {
+	ID       string
+	Checksum string
+	Index    *uint64 // nil until the entry is sequenced
+	// Reserved is set if the checksum was recorded by the call to Reserve that returned it
+	Reserved bool
+}
+
+// Store is a persistent index from package identity to log entries, backed by SQLite.
+// Entries are indexed in log order, so the size of the index is the number of log entries
+// that have been indexed.
+//
+// Store also records a single checksum per package identity, which is reserved when an entry
+// is submitted so that conflicting entries can be rejected before they are logged.
+//
+// Entries that have been sequenced but not yet indexed can be recorded, so that an identical
+// entry can be found as soon as it has an index.
+type Store struct {
+	db *sql.DB
+}
+
+// New opens or creates an index database at the given path
+func New(path string) (*Store, error) {
+	// Enable Write-Ahead Logging so lookups can be served while the index is updated
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=1000", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index database: %w", err)
+	}
+	// Serialize access so concurrent reservations don't fail with a locked database
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS entries (
+					idx INTEGER PRIMARY KEY,
+					id TEXT NOT NULL, -- pURL without checksum
+					checksum TEXT NOT NULL,
+					data BLOB NOT NULL -- raw log entry
+			);
+			CREATE INDEX IF NOT EXISTS entries_id ON entries (id);
+			CREATE INDEX IF NOT EXISTS entries_data ON entries (data);
+			CREATE TABLE IF NOT EXISTS sequenced (
+					data BLOB PRIMARY KEY, -- raw log entry
+					idx INTEGER NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS packages (
+					id TEXT PRIMARY KEY, -- pURL without checksum
+					checksum TEXT NOT NULL,
+					idx INTEGER -- NULL until the entry is sequenced
+			);
+			CREATE TABLE IF NOT EXISTS leaves (
+					leaf_hash BLOB PRIMARY KEY, -- RFC 6962 leaf hash of the raw log entry
+					idx INTEGER NOT NULL -- lowest index of an entry with the leaf hash
+			);
+	`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create index tables: %w", err)
+	}
+	s := &Store{db: db}
+	if err := s.addMissingLeaves(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to index leaf hashes: %w", err)
+	}
+	return s, nil
+}
+
+// addMissingLeaves indexes the leaf hashes of entries indexed before leaf hashes were recorded.
+// Leaf hashes are recorded with each entry, so either all or no entries have leaf hashes.
+func (s *Store) addMissingLeaves(ctx context.Context) error {
+	var leaves int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM leaves").Scan(&leaves); err != nil {
+		return err
+	}
+	if leaves > 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Read entries in pages, since rows can't be read while inserting on the same connection
+	const pageSize = 1000
+	for next := uint64(0); ; {
+		rows, err := tx.QueryContext(ctx, "SELECT idx, data FROM entries WHERE idx >= ? ORDER BY idx LIMIT ?", next, pageSize)
+		if err != nil {
+			return err
+		}
+		var entries []Entry
+		for rows.Next() {
+			var e Entry
+			if err := rows.Scan(&e.Index, &e.Data); err != nil {
+				rows.Close()
+				return err
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, e := range entries {
+			if err := addLeaf(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+		next = entries[len(entries)-1].Index + 1
+	}
+	return tx.Commit()
+}
+
+// addLeaf indexes the entry's leaf hash, keeping the lowest index for identical entries
+func addLeaf(ctx context.Context, tx *sql.Tx, e Entry) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO leaves (leaf_hash, idx) VALUES (?, ?) ON CONFLICT (leaf_hash) DO NOTHING",
+		rfc6962.DefaultHasher.HashLeaf(e.Data), e.Index)
+	return err
+}
+
+// Close closes the index database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Size returns the number of log entries that have been indexed
+func (s *Store) Size(ctx context.Context) (uint64, error) {
+	var size uint64
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(idx) + 1, 0) FROM entries").Scan(&size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Add indexes entries, which must be contiguous and start at the current size of the index.
+// The first checksum indexed for a package identity is recorded if none was reserved.
+func (s *Store) Add(ctx context.Context, entries []Entry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var size uint64
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(idx) + 1, 0) FROM entries").Scan(&size); err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if e.Index != size+uint64(i) {
+			return fmt.Errorf("entry index %d must follow index size %d", e.Index, size+uint64(i))
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO entries (idx, id, checksum, data) VALUES (?, ?, ?, ?)",
+			e.Index, e.ID, e.Checksum, e.Data); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+				INSERT INTO packages (id, checksum, idx) VALUES (?, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET idx = excluded.idx
+				WHERE packages.idx IS NULL AND packages.checksum = excluded.checksum`,
+			e.ID, e.Checksum, e.Index); err != nil {
+			return err
+		}
+		if err := addLeaf(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	// Sequenced entries can now be found by their indexed data
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sequenced WHERE idx < ?", size+uint64(len(entries))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddSequenced records the index of an entry that has been sequenced, before it is indexed.
+// If the same data was already recorded, the existing index is kept.
+func (s *Store) AddSequenced(ctx context.Context, data []byte, index uint64) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO sequenced (data, idx) VALUES (?, ?) ON CONFLICT (data) DO NOTHING",
+		data, index)
+	return err
+}
+
+// FindIndex returns the lowest index of a log entry with the given data, whether indexed or
+// only sequenced, or nil if no such entry is known
+func (s *Store) FindIndex(ctx context.Context, data []byte) (*uint64, error) {
+	var idx sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `
+			SELECT MIN(idx) FROM (
+					SELECT idx FROM entries WHERE data = ?
+					UNION ALL
+					SELECT idx FROM sequenced WHERE data = ?
+			)`, data, data).Scan(&idx); err != nil {
+		return nil, err
+	}
+	if !idx.Valid {
+		return nil, nil
+	}
+	i := uint64(idx.Int64)
+	return &i, nil
+}
+
+// FindLeafIndex returns the lowest index of an indexed log entry with the RFC 6962 leaf hash,
+// or nil if no such entry has been indexed
+func (s *Store) FindLeafIndex(ctx context.Context, leafHash []byte) (*uint64, error) {
+	var idx uint64
+	err := s.db.QueryRowContext(ctx, "SELECT idx FROM leaves WHERE leaf_hash = ?", leafHash).Scan(&idx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// Reserve records the checksum for a package identity if no checksum is recorded, and
+// returns the recorded package. If the returned checksum differs from the given checksum,
+// the package identity has already been reserved or logged with a different checksum.
+func (s *Store) Reserve(ctx context.Context, id, checksum string) (Package, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Package{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	r, err := tx.ExecContext(ctx, "INSERT INTO packages (id, checksum) VALUES (?, ?) ON CONFLICT (id) DO NOTHING",
+		id, checksum)
+	if err != nil {
+		return Package{}, err
+	}
+	inserted, err := r.RowsAffected()
+	if err != nil {
+		return Package{}, err
+	}
+	p := Package{ID: id, Reserved: inserted == 1}
+	var idx sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT checksum, idx FROM packages WHERE id = ?", id).Scan(&p.Checksum, &idx); err != nil {
+		return Package{}, err
+	}
+	if idx.Valid {
+		i := uint64(idx.Int64)
+		p.Index = &i
+	}
+	return p, tx.Commit()
+}
+
+// SetIndex records the log index for a reserved package identity
+func (s *Store) SetIndex(ctx context.Context, id string, index uint64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE packages SET idx = ? WHERE id = ? AND idx IS NULL", index, id)
+	return err
+}
+
+// Release removes a reservation for a package identity whose entry was not sequenced
+func (s *Store) Release(ctx context.Context, id, checksum string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM packages WHERE id = ? AND checksum = ? AND idx IS NULL", id, checksum)
+	return err
+}
+
+// Lookup returns all entries for a package identity, ordered by index
+func (s *Store) Lookup(ctx context.Context, id string) ([]Entry, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT idx, id, checksum, data FROM entries WHERE id = ? ORDER BY idx", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Index, &e.ID, &e.Checksum, &e.Data); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,231 @@
+package index
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	if size, err := s.Size(ctx); err != nil || size != 0 {
+		t.Fatalf("Size() = %d, %v, want 0", size, err)
+	}
+
+	entries := []Entry{
+		{Index: 0, ID: "pkg:pypi/a@1.0", Checksum: "sha256:00", Data: []byte("pkg:pypi/a@1.0?checksum=sha256:00")},
+		{Index: 1, ID: "pkg:pypi/b@1.0", Checksum: "sha256:01", Data: []byte("pkg:pypi/b@1.0?checksum=sha256:01")},
+		{Index: 2, ID: "pkg:pypi/a@1.0", Checksum: "sha256:02", Data: []byte("pkg:pypi/a@1.0?checksum=sha256:02")},
+	}
+	if err := s.Add(ctx, entries); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if size, err := s.Size(ctx); err != nil || size != 3 {
+		t.Fatalf("Size() = %d, %v, want 3", size, err)
+	}
+
+	got, err := s.Lookup(ctx, "pkg:pypi/a@1.0")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if want := []Entry{entries[0], entries[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup() = %v, want %v", got, want)
+	}
+
+	got, err = s.Lookup(ctx, "pkg:pypi/c@1.0")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Lookup() for unknown ID = %v, want no entries", got)
+	}
+
+	// Entries must be contiguous
+	if err := s.Add(ctx, []Entry{{Index: 4, ID: "pkg:pypi/c@1.0", Checksum: "sha256:04", Data: []byte("c")}}); err == nil {
+		t.Errorf("Add() with gap expected error, got nil")
+	}
+	if err := s.Add(ctx, []Entry{{Index: 2, ID: "pkg:pypi/c@1.0", Checksum: "sha256:02", Data: []byte("c")}}); err == nil {
+		t.Errorf("Add() with existing index expected error, got nil")
+	}
+	if size, err := s.Size(ctx); err != nil || size != 3 {
+		t.Fatalf("Size() after failed Add() = %d, %v, want 3", size, err)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	// First reservation records the checksum
+	p, err := s.Reserve(ctx, "pkg:pypi/a@1.0", "sha256:00")
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if p.Checksum != "sha256:00" || p.Index != nil || !p.Reserved {
+		t.Errorf("Reserve() = %+v, want new reservation of checksum sha256:00 and no index", p)
+	}
+
+	// A conflicting reservation returns the recorded checksum
+	p, err = s.Reserve(ctx, "pkg:pypi/a@1.0", "sha256:01")
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if p.Checksum != "sha256:00" || p.Reserved {
+		t.Errorf("Reserve() with conflict = %+v, want existing checksum sha256:00", p)
+	}
+
+	// Once sequenced, the index is returned
+	if err := s.SetIndex(ctx, "pkg:pypi/a@1.0", 5); err != nil {
+		t.Fatalf("SetIndex() error = %v", err)
+	}
+	p, err = s.Reserve(ctx, "pkg:pypi/a@1.0", "sha256:01")
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if p.Checksum != "sha256:00" || p.Index == nil || *p.Index != 5 {
+		t.Errorf("Reserve() = %+v, want checksum sha256:00 and index 5", p)
+	}
+
+	// Sequenced reservations can't be released
+	if err := s.Release(ctx, "pkg:pypi/a@1.0", "sha256:00"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if p, err := s.Reserve(ctx, "pkg:pypi/a@1.0", "sha256:01"); err != nil || p.Checksum != "sha256:00" {
+		t.Errorf("Reserve() after Release() = %+v, %v, want checksum sha256:00", p, err)
+	}
+
+	// Released reservations allow a new checksum
+	if _, err := s.Reserve(ctx, "pkg:pypi/b@1.0", "sha256:02"); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if err := s.Release(ctx, "pkg:pypi/b@1.0", "sha256:02"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if p, err := s.Reserve(ctx, "pkg:pypi/b@1.0", "sha256:03"); err != nil || p.Checksum != "sha256:03" {
+		t.Errorf("Reserve() after Release() = %+v, %v, want checksum sha256:03", p, err)
+	}
+
+	// Indexed entries record a checksum for unreserved identities and fill in reserved indices
+	if err := s.Add(ctx, []Entry{
+		{Index: 0, ID: "pkg:pypi/c@1.0", Checksum: "sha256:04", Data: []byte("c")},
+		{Index: 1, ID: "pkg:pypi/b@1.0", Checksum: "sha256:03", Data: []byte("b")},
+	}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if p, err := s.Reserve(ctx, "pkg:pypi/c@1.0", "sha256:05"); err != nil || p.Checksum != "sha256:04" || p.Index == nil || *p.Index != 0 {
+		t.Errorf("Reserve() for indexed entry = %+v, %v, want checksum sha256:04 and index 0", p, err)
+	}
+	if p, err := s.Reserve(ctx, "pkg:pypi/b@1.0", "sha256:03"); err != nil || p.Index == nil || *p.Index != 1 {
+		t.Errorf("Reserve() for indexed reservation = %+v, %v, want index 1", p, err)
+	}
+}
+
+func TestFindIndex(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer s.Close()
+
+	a := []byte("pkg:pypi/a@1.0?checksum=sha256:00")
+	b := []byte("pkg:pypi/b@1.0?checksum=sha256:01")
+	find := func(data []byte) *uint64 {
+		t.Helper()
+		idx, err := s.FindIndex(ctx, data)
+		if err != nil {
+			t.Fatalf("FindIndex() error = %v", err)
+		}
+		return idx
+	}
+
+	if idx := find(a); idx != nil {
+		t.Errorf("FindIndex() for unknown entry = %d, want nil", *idx)
+	}
+
+	// Sequenced entries are found before they're indexed
+	if err := s.AddSequenced(ctx, a, 0); err != nil {
+		t.Fatalf("AddSequenced() error = %v", err)
+	}
+	if err := s.AddSequenced(ctx, a, 5); err != nil {
+		t.Fatalf("AddSequenced() for recorded entry error = %v", err)
+	}
+	if idx := find(a); idx == nil || *idx != 0 {
+		t.Errorf("FindIndex() for sequenced entry = %v, want 0", idx)
+	}
+
+	// Indexed entries are found, and the lowest index of a duplicated entry is returned
+	if err := s.Add(ctx, []Entry{
+		{Index: 0, ID: "pkg:pypi/a@1.0", Checksum: "sha256:00", Data: a},
+		{Index: 1, ID: "pkg:pypi/b@1.0", Checksum: "sha256:01", Data: b},
+		{Index: 2, ID: "pkg:pypi/b@1.0", Checksum: "sha256:01", Data: b},
+	}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if idx := find(a); idx == nil || *idx != 0 {
+		t.Errorf("FindIndex() for indexed entry = %v, want 0", idx)
+	}
+	if idx := find(b); idx == nil || *idx != 1 {
+		t.Errorf("FindIndex() for duplicated entry = %v, want 1", idx)
+	}
+	var sequenced int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sequenced").Scan(&sequenced); err != nil || sequenced != 0 {
+		t.Errorf("sequenced entries after indexing = %d, %v, want 0", sequenced, err)
+	}
+}
+
+func TestFindLeafIndex(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "index.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a := []byte("pkg:pypi/a@1.0?checksum=sha256:00")
+	b := []byte("pkg:pypi/b@1.0?checksum=sha256:01")
+	if err := s.Add(ctx, []Entry{
+		{Index: 0, ID: "pkg:pypi/a@1.0", Checksum: "sha256:00", Data: a},
+		{Index: 1, ID: "pkg:pypi/b@1.0", Checksum: "sha256:01", Data: b},
+		{Index: 2, ID: "pkg:pypi/b@1.0", Checksum: "sha256:01", Data: b},
+	}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	check := func(s *Store) {
+		t.Helper()
+		for data, want := range map[string]uint64{string(a): 0, string(b): 1} {
+			if idx, err := s.FindLeafIndex(ctx, rfc6962.DefaultHasher.HashLeaf([]byte(data))); err != nil || idx == nil || *idx != want {
+				t.Errorf("FindLeafIndex() for %s = %v, %v, want %d", data, idx, err, want)
+			}
+		}
+		if idx, err := s.FindLeafIndex(ctx, rfc6962.DefaultHasher.HashLeaf([]byte("c"))); err != nil || idx != nil {
+			t.Errorf("FindLeafIndex() for unknown entry = %v, %v, want nil", idx, err)
+		}
+	}
+	check(s)
+
+	// Leaf hashes are indexed when an index created before leaf hashes were recorded is opened
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM leaves"); err != nil {
+		t.Fatalf("failed to delete leaf hashes: %v", err)
+	}
+	s.Close()
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("New() for existing index error = %v", err)
+	}
+	defer s.Close()
+	check(s)
+}
@@ -6,8 +6,14 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"strings"
+
+	"golang.org/x/mod/sumdb/note"
 )
 
+// algEd25519 is the note signature algorithm byte for Ed25519 keys
+const algEd25519 = 1
+
 func ParseEd25519PublicKey(keyStr string) (ed25519.PublicKey, error) {
 	block, _ := pem.Decode([]byte(keyStr))
 
@@ -38,3 +44,37 @@ func ParseEd25519PublicKey(keyStr string) (ed25519.PublicKey, error) {
 
 	return publicKey, nil
 }
+
+// VerifierKey returns the note verifier key for an Ed25519 note signer key
+func VerifierKey(skey string) (string, error) {
+	// Signer keys are PRIVATE+KEY+<name>+<hash>+<base64 of algorithm and seed>
+	parts := strings.SplitN(strings.TrimSpace(skey), "+", 5)
+	if len(parts) != 5 || parts[0] != "PRIVATE" || parts[1] != "KEY" {
+		return "", fmt.Errorf("malformed signer key")
+	}
+	b, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("failed to decode signer key: %w", err)
+	}
+	if len(b) != 1+ed25519.SeedSize || b[0] != algEd25519 {
+		return "", fmt.Errorf("signer key is not an Ed25519 key")
+	}
+	pub := ed25519.NewKeyFromSeed(b[1:]).Public().(ed25519.PublicKey)
+	vkey, err := note.NewEd25519VerifierKey(parts[2], pub)
+	if err != nil {
+		return "", err
+	}
+	// The key hash covers the name and public key, so a mismatch means a corrupt key
+	s, err := note.NewSigner(strings.TrimSpace(skey))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse signer key: %w", err)
+	}
+	v, err := note.NewVerifier(vkey)
+	if err != nil {
+		return "", err
+	}
+	if s.KeyHash() != v.KeyHash() {
+		return "", fmt.Errorf("signer key hash does not match its key")
+	}
+	return vkey, nil
+}
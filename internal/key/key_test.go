@@ -8,7 +8,10 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"strings"
 	"testing"
+
+	"golang.org/x/mod/sumdb/note"
 )
 
 func TestParseEd25519PublicKey(t *testing.T) {
@@ -113,3 +116,28 @@ func TestParseEd25519PublicKey(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifierKey(t *testing.T) {
+	skey, vkey, err := note.GenerateKey(rand.Reader, "witness.log/example")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	got, err := VerifierKey(skey + "\n")
+	if err != nil {
+		t.Fatalf("VerifierKey() error = %v", err)
+	}
+	if got != vkey {
+		t.Errorf("VerifierKey() = %s, want %s", got, vkey)
+	}
+
+	for name, skey := range map[string]string{
+		"verifier key":   vkey,
+		"empty":          "",
+		"invalid hash":   strings.Replace(skey, "+"+strings.Split(skey, "+")[3]+"+", "+00000000+", 1),
+		"invalid base64": "PRIVATE+KEY+witness.log/example+00000000+!!!",
+	} {
+		if _, err := VerifierKey(skey); err == nil {
+			t.Errorf("VerifierKey() with %s expected error, got nil", name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package keyring
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/key"
+	f_note "github.com/transparency-dev/formats/note"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// File is a JSON keyring file. One key is active and signs every note. Other keys are kept
+// so their verifier keys are still published, and keep signing until SignUntil, so a new key
+// can be rolled out while verifiers still trust the old key.
+type File struct {
+	Keys []Key `json:"keys"`
+}
+
+// Key is a keyring entry. Keys that sign need PrivateKeyPath, which is the path to a note signer
+// key. Keys that are only published may instead set PublicKey to their note verifier key.
+type Key struct {
+	PrivateKeyPath string    `json:"private_key_path,omitempty"`
+	PublicKey      string    `json:"public_key,omitempty"`
+	Active         bool      `json:"active,omitempty"`
+	SignUntil      time.Time `json:"sign_until,omitzero"`
+}
+
+// Keyring is a set of signing keys, where the active key and keys in their overlap period sign
+type Keyring struct {
+	active     note.Signer
+	overlap    []overlapSigner
+	publicKeys []string
+}
+
+type overlapSigner struct {
+	signer    note.Signer
+	signUntil time.Time
+}
+
+// Load reads a JSON keyring file. newSigner creates a signer from a note signer key,
+// e.g. note.NewSigner for log keys or NewCosigner for witness keys.
+func Load(path string, newSigner func(skey string) (note.Signer, error)) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring file: %w", err)
+	}
+	return New(f.Keys, newSigner)
+}
+
+// New returns a keyring for the keys, exactly one of which must be active
+func New(keys []Key, newSigner func(skey string) (note.Signer, error)) (*Keyring, error) {
+	k := &Keyring{}
+	seen := make(map[string]bool)
+	for i, e := range keys {
+		var signer note.Signer
+		var vkey string
+		switch {
+		case e.PrivateKeyPath != "" && e.PublicKey != "":
+			return nil, fmt.Errorf("key %d must set only one of private_key_path or public_key", i)
+		case e.PrivateKeyPath != "":
+			skey, err := os.ReadFile(e.PrivateKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key file for %s: %w", e.PrivateKeyPath, err)
+			}
+			if signer, err = newSigner(string(skey)); err != nil {
+				return nil, fmt.Errorf("failed to read signer %s: %w", e.PrivateKeyPath, err)
+			}
+			if vkey, err = key.VerifierKey(string(skey)); err != nil {
+				return nil, fmt.Errorf("failed to read signer %s: %w", e.PrivateKeyPath, err)
+			}
+		case e.PublicKey != "":
+			if e.Active || !e.SignUntil.IsZero() {
+				return nil, fmt.Errorf("key %d signs, so must set private_key_path", i)
+			}
+			if _, err := note.NewVerifier(e.PublicKey); err != nil {
+				return nil, fmt.Errorf("key %d has invalid public key: %w", i, err)
+			}
+			vkey = e.PublicKey
+		default:
+			return nil, fmt.Errorf("key %d must set private_key_path or public_key", i)
+		}
+		if seen[vkey] {
+			return nil, fmt.Errorf("key %d is listed more than once", i)
+		}
+		seen[vkey] = true
+
+		switch {
+		case e.Active && !e.SignUntil.IsZero():
+			return nil, fmt.Errorf("key %d is active, so can't set sign_until", i)
+		case e.Active && k.active != nil:
+			return nil, fmt.Errorf("keyring must have exactly one active key")
+		case e.Active:
+			k.active = signer
+			// The active key is published first
+			k.publicKeys = append([]string{vkey}, k.publicKeys...)
+			continue
+		case !e.SignUntil.IsZero():
+			k.overlap = append(k.overlap, overlapSigner{signer: signer, signUntil: e.SignUntil})
+		}
+		k.publicKeys = append(k.publicKeys, vkey)
+	}
+	if k.active == nil {
+		return nil, fmt.Errorf("keyring must have exactly one active key")
+	}
+	return k, nil
+}
+
+// NewCosigner creates a signer for witness cosignatures from a note signer key
+func NewCosigner(skey string) (note.Signer, error) {
+	return f_note.NewSignerForCosignatureV1(skey)
+}
+
+// Active returns the active signer
+func (k *Keyring) Active() note.Signer {
+	return k.active
+}
+
+// Signers returns the keys that sign at the given time, starting with the active key
+func (k *Keyring) Signers(now time.Time) []note.Signer {
+	signers := []note.Signer{k.active}
+	for _, o := range k.overlap {
+		if now.Before(o.signUntil) {
+			signers = append(signers, o.signer)
+		}
+	}
+	return signers
+}
+
+// PublicKeys returns the note verifier keys of every key, starting with the active key
+func (k *Keyring) PublicKeys() []string {
+	return k.publicKeys
+}
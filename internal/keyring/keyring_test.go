@@ -0,0 +1,121 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	f_note "github.com/transparency-dev/formats/note"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// writeKey generates a signer key, returning the path it was written to and its verifier key
+func writeKey(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	skey, vkey, err := note.GenerateKey(rand.Reader, "witness.log/example")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(skey), 0o600); err != nil {
+		t.Fatalf("error writing key: %v", err)
+	}
+	return path, vkey
+}
+
+func writeKeyring(t *testing.T, dir string, keys ...Key) string {
+	t.Helper()
+	b, err := json.Marshal(File{Keys: keys})
+	if err != nil {
+		t.Fatalf("failed to marshal keyring: %v", err)
+	}
+	path := filepath.Join(dir, "keyring.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("error writing keyring: %v", err)
+	}
+	return path
+}
+
+func TestKeyring(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, oldVKey := writeKey(t, dir, "old.key")
+	newPath, newVKey := writeKey(t, dir, "new.key")
+	_, retiredVKey := writeKey(t, dir, "retired.key")
+	signUntil := time.Unix(1700000000, 0)
+
+	k, err := Load(writeKeyring(t, dir,
+		Key{PublicKey: retiredVKey},
+		Key{PrivateKeyPath: oldPath, SignUntil: signUntil},
+		Key{PrivateKeyPath: newPath, Active: true},
+	), NewCosigner)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []string{newVKey, retiredVKey, oldVKey}
+	got := k.PublicKeys()
+	if len(got) != len(want) {
+		t.Fatalf("PublicKeys() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("PublicKeys()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	// Both keys sign during the overlap, and only the active key after it
+	for name, tc := range map[string]struct {
+		now  time.Time
+		want []string
+	}{
+		"during overlap": {signUntil.Add(-time.Second), []string{newVKey, oldVKey}},
+		"after overlap":  {signUntil, []string{newVKey}},
+	} {
+		signers := k.Signers(tc.now)
+		cp, err := note.Sign(&note.Note{Text: "witness.log/example\n1\nAAAA\n"}, signers...)
+		if err != nil {
+			t.Fatalf("note.Sign() %s error = %v", name, err)
+		}
+		if len(signers) != len(tc.want) {
+			t.Fatalf("Signers() %s returned %d signers, want %d", name, len(signers), len(tc.want))
+		}
+		for _, vkey := range tc.want {
+			v, err := f_note.NewVerifierForCosignatureV1(vkey)
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			if _, err := note.Open(cp, note.VerifierList(v)); err != nil {
+				t.Errorf("note signed %s does not verify with %s: %v", name, vkey, err)
+			}
+		}
+	}
+	if k.Active() != k.Signers(signUntil)[0] {
+		t.Errorf("Active() is not the first signer")
+	}
+}
+
+func TestKeyringInvalid(t *testing.T) {
+	dir := t.TempDir()
+	aPath, aVKey := writeKey(t, dir, "a.key")
+	bPath, _ := writeKey(t, dir, "b.key")
+	signUntil := time.Unix(1700000000, 0)
+	for name, keys := range map[string][]Key{
+		"no keys":                 nil,
+		"no active key":           {{PrivateKeyPath: aPath}},
+		"two active keys":         {{PrivateKeyPath: aPath, Active: true}, {PrivateKeyPath: bPath, Active: true}},
+		"active public key":       {{PublicKey: aVKey, Active: true}},
+		"overlapping public key":  {{PrivateKeyPath: bPath, Active: true}, {PublicKey: aVKey, SignUntil: signUntil}},
+		"active key with overlap": {{PrivateKeyPath: aPath, Active: true, SignUntil: signUntil}},
+		"duplicate key":           {{PrivateKeyPath: aPath, Active: true}, {PublicKey: aVKey}},
+		"both private and public": {{PrivateKeyPath: aPath, PublicKey: aVKey, Active: true}},
+		"missing key file":        {{PrivateKeyPath: filepath.Join(dir, "missing.key"), Active: true}},
+		"invalid public key":      {{PrivateKeyPath: aPath, Active: true}, {PublicKey: "not a key"}},
+	} {
+		if _, err := New(keys, note.NewSigner); err == nil {
+			t.Errorf("New() with %s expected error, got nil", name)
+		}
+	}
+}
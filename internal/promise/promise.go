@@ -0,0 +1,100 @@
+package promise
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// header is the first line of every promise. It ensures a promise, which is signed by
+// the log's checkpoint key, can never be parsed as a checkpoint.
+const header = "bt-log/inclusion-promise/v1"
+
+// Promise is a log's commitment that an entry has been sequenced at an index and will be
+// included in a published checkpoint before the deadline, similar to an RFC 6962 SCT.
+type Promise struct {
+	Origin   string
+	Index    uint64
+	LeafHash []byte
+	Deadline time.Time
+}
+
+// Marshal returns the promise in its note text format:
+//
+//	bt-log/inclusion-promise/v1
+//	<origin>
+//	<index>
+//	<base64 leaf hash>
+//	<deadline as unix seconds>
+func (p Promise) Marshal() []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%d\n%s\n%d\n",
+		header, p.Origin, p.Index, base64.StdEncoding.EncodeToString(p.LeafHash), p.Deadline.Unix()))
+}
+
+// Unmarshal parses a promise from its note text format
+func (p *Promise) Unmarshal(text []byte) error {
+	lines := strings.Split(string(text), "\n")
+	if len(lines) != 6 || lines[5] != "" {
+		return fmt.Errorf("promise must contain 5 newline-terminated lines")
+	}
+	if lines[0] != header {
+		return fmt.Errorf("promise header must be %s, was %s", header, lines[0])
+	}
+	if lines[1] == "" {
+		return fmt.Errorf("promise missing origin")
+	}
+	index, err := strconv.ParseUint(lines[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("error parsing promise index: %w", err)
+	}
+	leafHash, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return fmt.Errorf("error parsing promise leaf hash: %w", err)
+	}
+	if len(leafHash) != 32 {
+		return fmt.Errorf("promise leaf hash must be 32 bytes, was %d", len(leafHash))
+	}
+	deadline, err := strconv.ParseInt(lines[4], 10, 64)
+	if err != nil {
+		return fmt.Errorf("error parsing promise deadline: %w", err)
+	}
+	*p = Promise{
+		Origin:   lines[1],
+		Index:    index,
+		LeafHash: leafHash,
+		Deadline: time.Unix(deadline, 0),
+	}
+	return nil
+}
+
+// Sign returns the promise as a signed note. Additional signers are other keys for the same
+// origin, e.g. while the log's key is being rotated.
+func Sign(p Promise, s note.Signer, additionalSigners ...note.Signer) ([]byte, error) {
+	signers := append([]note.Signer{s}, additionalSigners...)
+	for _, s := range signers {
+		if p.Origin != s.Name() {
+			return nil, fmt.Errorf("promise origin %s must match signer name %s", p.Origin, s.Name())
+		}
+	}
+	return note.Sign(&note.Note{Text: string(p.Marshal())}, signers...)
+}
+
+// Verify verifies the signed note was signed by the log and returns the parsed promise
+func Verify(msg []byte, v note.Verifier) (*Promise, error) {
+	n, err := note.Open(msg, note.VerifierList(v))
+	if err != nil {
+		return nil, err
+	}
+	var p Promise
+	if err := p.Unmarshal([]byte(n.Text)); err != nil {
+		return nil, err
+	}
+	if p.Origin != v.Name() {
+		return nil, fmt.Errorf("promise origin %s must match verifier name %s", p.Origin, v.Name())
+	}
+	return &p, nil
+}
@@ -0,0 +1,136 @@
+package promise
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+func TestSignVerify(t *testing.T) {
+	skey, vkey, err := note.GenerateKey(rand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	s, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	v, err := note.NewVerifier(vkey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	otherSkey, otherVkey, err := note.GenerateKey(rand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherS, err := note.NewSigner(otherSkey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	otherV, err := note.NewVerifier(otherVkey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	leafHash := sha256.Sum256([]byte("leaf"))
+	p := Promise{
+		Origin:   "example.com/log",
+		Index:    42,
+		LeafHash: leafHash[:],
+		Deadline: time.Unix(1700000000, 0),
+	}
+
+	signed, err := Sign(p, s)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	got, err := Verify(signed, v)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got.Origin != p.Origin || got.Index != p.Index || !bytes.Equal(got.LeafHash, p.LeafHash) || !got.Deadline.Equal(p.Deadline) {
+		t.Errorf("Verify() = %+v, want %+v", got, p)
+	}
+
+	if _, err := Verify(signed, otherV); err == nil {
+		t.Errorf("Verify() with wrong key expected error, got nil")
+	}
+
+	// A promise signed by both keys during a key rotation verifies with either key
+	signed, err = Sign(p, s, otherS)
+	if err != nil {
+		t.Fatalf("Sign() with additional signer error = %v", err)
+	}
+	for _, v := range []note.Verifier{v, otherV} {
+		if _, err := Verify(signed, v); err != nil {
+			t.Errorf("Verify() of promise with additional signer error = %v", err)
+		}
+	}
+
+	p.Origin = "example.com/other"
+	if _, err := Sign(p, s); err == nil {
+		t.Errorf("Sign() with mismatched origin expected error, got nil")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	leafHash := sha256.Sum256([]byte("leaf"))
+	valid := string(Promise{Origin: "example.com/log", Index: 1, LeafHash: leafHash[:], Deadline: time.Unix(10, 0)}.Marshal())
+
+	tests := []struct {
+		name       string
+		text       string
+		wantErrMsg string
+	}{
+		{
+			name: "Valid promise",
+			text: valid,
+		},
+		{
+			name:       "Wrong header",
+			text:       strings.Replace(valid, header, "example.com/log", 1),
+			wantErrMsg: "promise header must be",
+		},
+		{
+			name:       "Missing trailing newline",
+			text:       strings.TrimSuffix(valid, "\n"),
+			wantErrMsg: "promise must contain 5 newline-terminated lines",
+		},
+		{
+			name:       "Invalid index",
+			text:       strings.Replace(valid, "\n1\n", "\n-1\n", 1),
+			wantErrMsg: "error parsing promise index",
+		},
+		{
+			name:       "Short leaf hash",
+			text:       header + "\nexample.com/log\n1\nAAAA\n10\n",
+			wantErrMsg: "promise leaf hash must be 32 bytes",
+		},
+		{
+			name:       "Checkpoint is not a promise",
+			text:       "example.com/log\n1\nAAAA\n",
+			wantErrMsg: "promise must contain 5 newline-terminated lines",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Promise
+			err := p.Unmarshal([]byte(tt.text))
+			if tt.wantErrMsg == "" {
+				if err != nil {
+					t.Errorf("Unmarshal() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+				t.Errorf("Unmarshal() error = %v, want error containing %q", err, tt.wantErrMsg)
+			}
+		})
+	}
+}
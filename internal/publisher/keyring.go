@@ -0,0 +1,100 @@
+package publisher
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/haydentherapper/bt-log/internal/key"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// Keyring contains the public keys of publishers allowed to sign submissions
+type Keyring struct {
+	keys      map[string]ed25519.PublicKey // Key ID to Ed25519 key, for detached signatures
+	verifiers []note.Verifier              // Note verifiers, whose names are the key IDs
+}
+
+// LoadKeyring reads a keyring file. Each line contains either a key ID and a base64-encoded
+// DER Ed25519 public key separated by whitespace, for detached signatures, or a note verifier
+// key, e.g. publisher+abcd1234+AQ..., for signed notes. Lines starting with # are ignored.
+func LoadKeyring(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file: %w", err)
+	}
+	k := &Keyring{keys: make(map[string]ed25519.PublicKey)}
+	ids := make(map[string]bool)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var id string
+		switch fields := strings.Fields(text); len(fields) {
+		case 1:
+			v, err := note.NewVerifier(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("keyring line %d: %w", line, err)
+			}
+			id = v.Name()
+			k.verifiers = append(k.verifiers, v)
+		case 2:
+			pub, err := key.ParseEd25519PublicKey(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("keyring line %d: %w", line, err)
+			}
+			// Each key must have one ID, so the signing key ID is unambiguous
+			for existing, other := range k.keys {
+				if other.Equal(pub) {
+					return nil, fmt.Errorf("keyring line %d: key is already listed as %s", line, existing)
+				}
+			}
+			id = fields[0]
+			k.keys[id] = pub
+		default:
+			return nil, fmt.Errorf("keyring line %d must contain a key ID and key, or a note verifier key", line)
+		}
+		if ids[id] {
+			return nil, fmt.Errorf("keyring line %d: duplicate key ID %s", line, id)
+		}
+		ids[id] = true
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// VerifySignature verifies a detached Ed25519 signature over the message, and returns the ID
+// of the key that made the signature
+func (k *Keyring) VerifySignature(msg, sig []byte) (string, error) {
+	for id, pub := range k.keys {
+		if ed25519.Verify(pub, msg, sig) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("signature not made by a key in the publisher keyring")
+}
+
+// VerifyNote verifies a signed note whose text is the message, and returns the ID of the first
+// key in the keyring that signed the note
+func (k *Keyring) VerifyNote(msg []byte, signedNote []byte) (string, error) {
+	n, err := note.Open(signedNote, note.VerifierList(k.verifiers...))
+	if err != nil {
+		var unverified *note.UnverifiedNoteError
+		if errors.As(err, &unverified) {
+			return "", fmt.Errorf("note not signed by a key in the publisher keyring")
+		}
+		return "", err
+	}
+	if n.Text != string(msg) {
+		return "", fmt.Errorf("note text must be the signed message")
+	}
+	return n.Sigs[0].Name, nil
+}
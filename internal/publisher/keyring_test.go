@@ -0,0 +1,120 @@
+package publisher
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+func writeKeyring(t *testing.T, contents string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "keyring")
+	if err := os.WriteFile(p, []byte(contents), 0o600); err != nil {
+		t.Fatalf("error writing keyring: %v", err)
+	}
+	return p
+}
+
+func ed25519Key(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der), priv
+}
+
+func noteKey(t *testing.T, name string) (string, note.Signer) {
+	t.Helper()
+	skey, vkey, err := note.GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatalf("failed to generate note key: %v", err)
+	}
+	s, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("failed to create note signer: %v", err)
+	}
+	return vkey, s
+}
+
+func TestVerifySignature(t *testing.T) {
+	alicePub, alicePriv := ed25519Key(t)
+	bobPub, bobPriv := ed25519Key(t)
+	_, malloryPriv := ed25519Key(t)
+	k, err := LoadKeyring(writeKeyring(t, "# publishers\nalice "+alicePub+"\n\nbob "+bobPub+"\n"))
+	if err != nil {
+		t.Fatalf("LoadKeyring() error = %v", err)
+	}
+
+	msg := []byte("pkg:pypi/pkgname@1.2.3?checksum=sha256:5141b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be92")
+	if id, err := k.VerifySignature(msg, ed25519.Sign(alicePriv, msg)); err != nil || id != "alice" {
+		t.Errorf("VerifySignature() = %s, %v, want alice", id, err)
+	}
+	if id, err := k.VerifySignature(msg, ed25519.Sign(bobPriv, msg)); err != nil || id != "bob" {
+		t.Errorf("VerifySignature() = %s, %v, want bob", id, err)
+	}
+	if _, err := k.VerifySignature(msg, ed25519.Sign(malloryPriv, msg)); err == nil {
+		t.Errorf("VerifySignature() with unknown key expected error, got nil")
+	}
+	if _, err := k.VerifySignature([]byte("pkg:pypi/other@1.2.3"), ed25519.Sign(alicePriv, msg)); err == nil {
+		t.Errorf("VerifySignature() with different message expected error, got nil")
+	}
+}
+
+func TestVerifyNote(t *testing.T) {
+	aliceVkey, aliceSigner := noteKey(t, "alice")
+	_, mallorySigner := noteKey(t, "mallory")
+	k, err := LoadKeyring(writeKeyring(t, aliceVkey+"\n"))
+	if err != nil {
+		t.Fatalf("LoadKeyring() error = %v", err)
+	}
+
+	msg := []byte("bt-log/publisher-submission/v1\npkg:pypi/pkgname@1.2.3\n\n\n")
+	sign := func(text string, s note.Signer) []byte {
+		signed, err := note.Sign(&note.Note{Text: text}, s)
+		if err != nil {
+			t.Fatalf("failed to sign note: %v", err)
+		}
+		return signed
+	}
+
+	if id, err := k.VerifyNote(msg, sign(string(msg), aliceSigner)); err != nil || id != "alice" {
+		t.Errorf("VerifyNote() = %s, %v, want alice", id, err)
+	}
+	if _, err := k.VerifyNote(msg, sign(string(msg), mallorySigner)); err == nil || !strings.Contains(err.Error(), "not signed by a key in the publisher keyring") {
+		t.Errorf("VerifyNote() with unknown key error = %v, want not signed by a key in the publisher keyring", err)
+	}
+	if _, err := k.VerifyNote(msg, sign("pkg:pypi/pkgname@1.2.3\n", aliceSigner)); err == nil || !strings.Contains(err.Error(), "note text must be the signed message") {
+		t.Errorf("VerifyNote() with different text error = %v, want note text must be the signed message", err)
+	}
+	if _, err := k.VerifyNote(msg, []byte("not a note")); err == nil {
+		t.Errorf("VerifyNote() with malformed note expected error, got nil")
+	}
+}
+
+func TestLoadKeyringInvalid(t *testing.T) {
+	alicePub, _ := ed25519Key(t)
+	aliceVkey, _ := noteKey(t, "alice")
+	for name, contents := range map[string]string{
+		"invalid Ed25519 key":     "alice not-a-key",
+		"invalid note key":        "alice+1234+AAAA",
+		"too many fields":         "alice " + alicePub + " extra",
+		"duplicate key ID":        "alice " + alicePub + "\n" + aliceVkey,
+		"key listed with two IDs": "alice " + alicePub + "\nbob " + alicePub,
+	} {
+		if _, err := LoadKeyring(writeKeyring(t, contents)); err == nil {
+			t.Errorf("LoadKeyring() with %s expected error, got nil", name)
+		}
+	}
+}
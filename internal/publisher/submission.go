@@ -0,0 +1,31 @@
+package publisher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// submissionContext is the first line of a signed submission, so that a publisher's signature
+// over a submission can't be mistaken for a signature over any other message
+const submissionContext = "bt-log/publisher-submission/v1"
+
+// Submission is an entry a publisher signs to ask the log to record it
+type Submission struct {
+	PURL     string
+	Filename string
+	// Submitter is the identity the entry is submitted under, so the signature can't be replayed
+	// by another submitter. It's empty if submitters aren't authenticated.
+	Submitter string
+}
+
+// Message returns the message a publisher signs for the submission, which is the submission
+// context followed by the pURL, filename and submitter, each on its own line
+func (s Submission) Message() ([]byte, error) {
+	fields := []string{s.PURL, s.Filename, s.Submitter}
+	for _, f := range fields {
+		if strings.ContainsAny(f, "\r\n") {
+			return nil, fmt.Errorf("signed submission fields must not contain newlines")
+		}
+	}
+	return []byte(submissionContext + "\n" + strings.Join(fields, "\n") + "\n"), nil
+}
@@ -0,0 +1,38 @@
+package publisher
+
+import "testing"
+
+func TestSubmissionMessage(t *testing.T) {
+	s := Submission{
+		PURL:      "pkg:pypi/pkgname@1.2.3?checksum=sha256:5141b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be92",
+		Filename:  "pkgname-1.2.3.tar.gz",
+		Submitter: "token:ci",
+	}
+	msg, err := s.Message()
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+	want := "bt-log/publisher-submission/v1\n" + s.PURL + "\npkgname-1.2.3.tar.gz\ntoken:ci\n"
+	if string(msg) != want {
+		t.Errorf("Message() = %q, want %q", msg, want)
+	}
+
+	// The same pURL signed for another filename or submitter is a different message
+	for _, other := range []Submission{
+		{PURL: s.PURL, Filename: "pkgname-1.2.3-py3-none-any.whl", Submitter: s.Submitter},
+		{PURL: s.PURL, Filename: s.Filename, Submitter: "token:mallory"},
+	} {
+		if m, err := other.Message(); err != nil || string(m) == string(msg) {
+			t.Errorf("Message() for %+v = %q, %v, want different message", other, m, err)
+		}
+	}
+
+	for _, s := range []Submission{
+		{PURL: s.PURL, Filename: "a\ntoken:ci"},
+		{PURL: s.PURL, Submitter: "token:ci\r"},
+	} {
+		if _, err := s.Message(); err == nil {
+			t.Errorf("Message() for %+v with newline expected error, got nil", s)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package purl
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// checksumAlgorithm describes a supported checksum algorithm
+type checksumAlgorithm struct {
+	// size is the digest size in bytes
+	size int
+	// strength is the collision resistance in bits
+	strength int
+}
+
+var checksumAlgorithms = map[string]checksumAlgorithm{
+	"sha1":        {size: 20, strength: 63},
+	"sha256":      {size: 32, strength: 128},
+	"sha384":      {size: 48, strength: 192},
+	"sha512":      {size: 64, strength: 256},
+	"sha3-256":    {size: 32, strength: 128},
+	"sha3-512":    {size: 64, strength: 256},
+	"blake2b-256": {size: 32, strength: 128},
+	"blake2b-512": {size: 64, strength: 256},
+}
+
+// ChecksumPolicy configures the checksums accepted in a pURL
+type ChecksumPolicy struct {
+	// Algorithms are the allowed checksum algorithms
+	Algorithms []string
+	// MinStrength is the collision resistance in bits that at least one checksum must provide
+	MinStrength int
+}
+
+// DefaultChecksumPolicy only accepts SHA256 checksums
+var DefaultChecksumPolicy = ChecksumPolicy{Algorithms: []string{"sha256"}}
+
+// validate returns an error if an algorithm is unsupported, or no allowed algorithm
+// can satisfy the minimum strength
+func (p ChecksumPolicy) validate() error {
+	if len(p.Algorithms) == 0 {
+		return fmt.Errorf("at least one checksum algorithm must be allowed")
+	}
+	strongest := 0
+	for _, a := range p.Algorithms {
+		alg, ok := checksumAlgorithms[a]
+		if !ok {
+			return fmt.Errorf("unsupported checksum algorithm %s", a)
+		}
+		strongest = max(strongest, alg.strength)
+	}
+	if strongest < p.MinStrength {
+		return fmt.Errorf("no allowed checksum algorithm has a strength of at least %d bits", p.MinStrength)
+	}
+	return nil
+}
+
+// SupportedChecksumAlgorithms returns the names of all supported checksum algorithms, sorted
+func SupportedChecksumAlgorithms() []string {
+	names := make([]string, 0, len(checksumAlgorithms))
+	for name := range checksumAlgorithms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseChecksums parses a checksum qualifier, a comma-separated list of algorithm:hex-encoded-digest,
+// into a map from algorithm to digest. Each algorithm must be one of the allowed algorithms.
+func ParseChecksums(checksum string, allowed []string) (map[string]string, error) {
+	checksums := make(map[string]string)
+	for _, c := range strings.Split(checksum, ",") {
+		funcAndChecksum := strings.Split(c, ":")
+		if len(funcAndChecksum) != 2 {
+			return nil, fmt.Errorf("pURL checksum must be %s:hex-encoded-checksum", strings.Join(allowed, " or "))
+		}
+		name, digest := funcAndChecksum[0], funcAndChecksum[1]
+		alg, ok := checksumAlgorithms[name]
+		if !ok || !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("pURL checksum must start with %s", strings.Join(allowed, " or "))
+		}
+		if _, err := hex.DecodeString(digest); err != nil {
+			return nil, fmt.Errorf("pURL checksum must be hex-encoded")
+		}
+		if len(digest) != 2*alg.size {
+			return nil, fmt.Errorf("pURL checksum must be hex-encoded %s checksum", strings.ToUpper(name))
+		}
+		if _, ok := checksums[name]; ok {
+			return nil, fmt.Errorf("pURL must contain only one %s checksum", name)
+		}
+		checksums[name] = digest
+	}
+	return checksums, nil
+}
+
+// ErrNoSharedChecksum is returned when two checksum qualifiers share no algorithm, so their
+// digests can't be compared
+var ErrNoSharedChecksum = errors.New("checksums share no algorithm")
+
+// ChecksumsMatch returns true if the digests for every algorithm shared by two checksum
+// qualifiers are equal. Returns ErrNoSharedChecksum if they share no algorithm, and false if
+// either qualifier is invalid.
+func ChecksumsMatch(a, b string) (bool, error) {
+	allowed := SupportedChecksumAlgorithms()
+	aChecksums, err := ParseChecksums(a, allowed)
+	if err != nil {
+		return false, nil
+	}
+	bChecksums, err := ParseChecksums(b, allowed)
+	if err != nil {
+		return false, nil
+	}
+	shared := false
+	for name, aDigest := range aChecksums {
+		if bDigest, ok := bChecksums[name]; ok {
+			if !strings.EqualFold(aDigest, bDigest) {
+				return false, nil
+			}
+			shared = true
+		}
+	}
+	if !shared {
+		return false, ErrNoSharedChecksum
+	}
+	return true, nil
+}
+
+// verifyChecksums verifies a checksum qualifier only uses allowed algorithms, and that at least
+// one checksum satisfies the policy's minimum strength
+func verifyChecksums(checksum string, p ChecksumPolicy) error {
+	checksums, err := ParseChecksums(checksum, p.Algorithms)
+	if err != nil {
+		return err
+	}
+	for name := range checksums {
+		if checksumAlgorithms[name].strength >= p.MinStrength {
+			return nil
+		}
+	}
+	return fmt.Errorf("pURL must contain a checksum with a strength of at least %d bits", p.MinStrength)
+}
@@ -0,0 +1,134 @@
+package purl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChecksumPolicy(t *testing.T) {
+	sha1 := "sha1:ad9503c3e994a4f611a4892f2e67ac82df727086"
+	sha256 := "sha256:3b9730808f265c6d174662668435c4cf1fc9ddcd369831a646fa84bff8594f0c"
+	sha512 := "sha512:" + strings.Repeat("ab", 64)
+	blake2b := "blake2b-256:" + strings.Repeat("cd", 32)
+
+	v, err := NewVerifier("npm").WithChecksumPolicy(ChecksumPolicy{
+		Algorithms:  []string{"sha1", "sha512", "blake2b-256"},
+		MinStrength: 128,
+	})
+	if err != nil {
+		t.Fatalf("WithChecksumPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		checksum   string
+		wantErrMsg string
+	}{
+		{
+			name:     "Allowed algorithm",
+			checksum: sha512,
+		},
+		{
+			name:     "Allowed blake2b algorithm",
+			checksum: blake2b,
+		},
+		{
+			name:     "Weak checksum with strong checksum",
+			checksum: sha1 + "," + sha512,
+		},
+		{
+			name:       "Only weak checksum",
+			checksum:   sha1,
+			wantErrMsg: "pURL must contain a checksum with a strength of at least 128 bits",
+		},
+		{
+			name:       "Disallowed algorithm",
+			checksum:   sha256,
+			wantErrMsg: "pURL checksum must start with sha1 or sha512 or blake2b-256",
+		},
+		{
+			name:       "Disallowed algorithm with allowed algorithm",
+			checksum:   sha512 + "," + sha256,
+			wantErrMsg: "pURL checksum must start with sha1 or sha512 or blake2b-256",
+		},
+		{
+			name:       "Unsupported algorithm",
+			checksum:   "md5:" + strings.Repeat("ab", 16),
+			wantErrMsg: "pURL checksum must start with sha1 or sha512 or blake2b-256",
+		},
+		{
+			name:       "Digest length for another algorithm",
+			checksum:   "sha512:" + strings.Repeat("ab", 32),
+			wantErrMsg: "pURL checksum must be hex-encoded SHA512 checksum",
+		},
+		{
+			name:       "Duplicate algorithm",
+			checksum:   sha512 + "," + sha512,
+			wantErrMsg: "pURL must contain only one sha512 checksum",
+		},
+		{
+			name:       "Missing digest",
+			checksum:   sha512 + ",",
+			wantErrMsg: "pURL checksum must be sha1 or sha512 or blake2b-256:hex-encoded-checksum",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify("pkg:npm/my-package@1.2.3?checksum=" + tt.checksum)
+			if tt.wantErrMsg == "" {
+				if err != nil {
+					t.Errorf("Verify() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+				t.Errorf("Verify() error = %v, should contain %v", err, tt.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestInvalidChecksumPolicy(t *testing.T) {
+	for _, p := range []ChecksumPolicy{
+		{},
+		{Algorithms: []string{"md5"}},
+		{Algorithms: []string{"sha1", "sha256"}, MinStrength: 192},
+	} {
+		if _, err := NewVerifier("npm").WithChecksumPolicy(p); err == nil {
+			t.Errorf("WithChecksumPolicy(%+v) expected error, got nil", p)
+		}
+	}
+}
+
+func TestChecksumsMatch(t *testing.T) {
+	sha256 := "sha256:" + strings.Repeat("ab", 32)
+	sha512 := "sha512:" + strings.Repeat("cd", 64)
+
+	tests := []struct {
+		name    string
+		a, b    string
+		want    bool
+		wantErr error
+	}{
+		{name: "Same checksum", a: sha256, b: sha256, want: true},
+		{name: "Uppercase algorithm", a: sha256, b: "SHA256:" + strings.Repeat("ab", 32), want: false},
+		{name: "Uppercase digest", a: sha256, b: "sha256:" + strings.Repeat("AB", 32), want: true},
+		{name: "Different digest", a: sha256, b: "sha256:" + strings.Repeat("ef", 32), want: false},
+		{name: "Shared algorithm", a: sha256, b: sha512 + "," + sha256, want: true},
+		{name: "Shared algorithm with different digest", a: sha256 + "," + sha512, b: sha256 + ",sha512:" + strings.Repeat("ef", 64), want: false},
+		{name: "No shared algorithm", a: sha256, b: sha512, wantErr: ErrNoSharedChecksum},
+		{name: "No shared algorithm with several checksums", a: sha256 + ",sha1:" + strings.Repeat("ab", 20), b: sha512, wantErr: ErrNoSharedChecksum},
+		{name: "Invalid checksum", a: sha256, b: "sha256", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ChecksumsMatch(tt.a, tt.b)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ChecksumsMatch(%s, %s) = %v, %v, want %v, %v", tt.a, tt.b, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package purl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/package-url/packageurl-go"
+)
+
+var (
+	// PEP 503 normalized name, lowercase with runs of -, _ and . replaced by a single -
+	pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+	// PEP 440 normalized version, e.g. 1!2.0rc1.post2.dev3+local.1
+	pep440Version = regexp.MustCompile(`^([0-9]+!)?(0|[1-9][0-9]*)(\.(0|[1-9][0-9]*))*((a|b|rc)(0|[1-9][0-9]*))?(\.post(0|[1-9][0-9]*))?(\.dev(0|[1-9][0-9]*))?(\+[a-z0-9]+(\.[a-z0-9]+)*)?$`)
+	// Semantic version without a leading v, per https://semver.org
+	semVer = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-((0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(\.(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(\+([0-9a-zA-Z-]+(\.[0-9a-zA-Z-]+)*))?$`)
+	// npm package name and scope, which must be lowercase and URL-safe
+	npmName  = regexp.MustCompile(`^[a-z0-9-~][a-z0-9-._~]*$`)
+	npmScope = regexp.MustCompile(`^@[a-z0-9-~][a-z0-9-._~]*$`)
+)
+
+// ecosystemRules maps a pURL type to rules enforcing its package registry's naming and
+// versioning conventions, so that one package identity can't be logged under several pURLs
+var ecosystemRules = map[string][]Rule{
+	"pypi":  {noNamespace, pypiNormalizedName, pep440NormalizedVersion},
+	"npm":   {npmScopedName, semVerVersion},
+	"maven": {requireNamespace},
+	"cargo": {noNamespace, semVerVersion},
+}
+
+// EcosystemRules returns the rules for a pURL type's package registry conventions,
+// or nil if there are no rules for the type
+func EcosystemRules(purlType string) []Rule {
+	return ecosystemRules[purlType]
+}
+
+func noNamespace(purl packageurl.PackageURL) error {
+	if purl.Namespace != "" {
+		return fmt.Errorf("%s pURL must not contain namespace", purl.Type)
+	}
+	return nil
+}
+
+func requireNamespace(purl packageurl.PackageURL) error {
+	if purl.Namespace == "" {
+		return fmt.Errorf("%s pURL must contain namespace", purl.Type)
+	}
+	return nil
+}
+
+func pypiNormalizedName(purl packageurl.PackageURL) error {
+	normalized := strings.ToLower(pypiNameSeparators.ReplaceAllString(purl.Name, "-"))
+	if purl.Name != normalized {
+		return fmt.Errorf("pypi pURL name must be normalized as %s, was %s", normalized, purl.Name)
+	}
+	return nil
+}
+
+func pep440NormalizedVersion(purl packageurl.PackageURL) error {
+	if !pep440Version.MatchString(purl.Version) {
+		return fmt.Errorf("pypi pURL version must be a normalized PEP 440 version, was %s", purl.Version)
+	}
+	return nil
+}
+
+func npmScopedName(purl packageurl.PackageURL) error {
+	if purl.Namespace != "" && !npmScope.MatchString(purl.Namespace) {
+		return fmt.Errorf("npm pURL namespace must be a lowercase scope starting with @, was %s", purl.Namespace)
+	}
+	if !npmName.MatchString(purl.Name) {
+		return fmt.Errorf("npm pURL name must be lowercase and URL-safe, was %s", purl.Name)
+	}
+	return nil
+}
+
+func semVerVersion(purl packageurl.PackageURL) error {
+	if !semVer.MatchString(purl.Version) {
+		return fmt.Errorf("%s pURL version must be a semantic version, was %s", purl.Type, purl.Version)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package purl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEcosystemRules(t *testing.T) {
+	types := []string{"pypi", "npm", "maven", "cargo", "generic"}
+	v := NewVerifier(types...)
+	for _, purlType := range types {
+		v.WithRules(purlType, EcosystemRules(purlType)...)
+	}
+
+	checksum := "?checksum=sha256:3b9730808f265c6d174662668435c4cf1fc9ddcd369831a646fa84bff8594f0c"
+	tests := []struct {
+		name       string
+		purlString string
+		wantErrMsg string
+	}{
+		{
+			name:       "Valid pypi pURL",
+			purlString: "pkg:pypi/my-package@1.2.3" + checksum,
+		},
+		{
+			name:       "Valid pypi pURL with pre, post and dev release",
+			purlString: "pkg:pypi/my-package@1!2.0rc1.post2.dev3+local.1" + checksum,
+		},
+		{
+			name:       "pypi name with uppercase",
+			purlString: "pkg:pypi/My-Package@1.2.3" + checksum,
+			wantErrMsg: "pURL must be in canonical form pkg:pypi/my-package@1.2.3, was pkg:pypi/My-Package@1.2.3",
+		},
+		{
+			name:       "pypi name with underscore",
+			purlString: "pkg:pypi/my_package@1.2.3" + checksum,
+			wantErrMsg: "pURL must be in canonical form pkg:pypi/my-package@1.2.3, was pkg:pypi/my_package@1.2.3",
+		},
+		{
+			name:       "pypi name with repeated separators",
+			purlString: "pkg:pypi/my-.package@1.2.3" + checksum,
+			wantErrMsg: "pypi pURL name must be normalized as my-package, was my-.package",
+		},
+		{
+			name:       "pypi with namespace",
+			purlString: "pkg:pypi/namespace/my-package@1.2.3" + checksum,
+			wantErrMsg: "pypi pURL must not contain namespace",
+		},
+		{
+			name:       "pypi version with v prefix",
+			purlString: "pkg:pypi/my-package@v1.2.3" + checksum,
+			wantErrMsg: "pypi pURL version must be a normalized PEP 440 version, was v1.2.3",
+		},
+		{
+			name:       "pypi version not normalized",
+			purlString: "pkg:pypi/my-package@1.2.3-alpha1" + checksum,
+			wantErrMsg: "pypi pURL version must be a normalized PEP 440 version",
+		},
+		{
+			name:       "Valid npm pURL",
+			purlString: "pkg:npm/my-package@1.2.3" + checksum,
+		},
+		{
+			name:       "Valid scoped npm pURL",
+			purlString: "pkg:npm/%40scope/my-package@1.2.3-beta.1+build.5" + checksum,
+		},
+		{
+			name:       "npm namespace without @",
+			purlString: "pkg:npm/scope/my-package@1.2.3" + checksum,
+			wantErrMsg: "npm pURL namespace must be a lowercase scope starting with @, was scope",
+		},
+		{
+			name:       "npm name with uppercase",
+			purlString: "pkg:npm/My-Package@1.2.3" + checksum,
+			wantErrMsg: "pURL must be in canonical form pkg:npm/my-package@1.2.3, was pkg:npm/My-Package@1.2.3",
+		},
+		{
+			name:       "npm name not URL-safe",
+			purlString: "pkg:npm/.my-package@1.2.3" + checksum,
+			wantErrMsg: "npm pURL name must be lowercase and URL-safe, was .my-package",
+		},
+		{
+			name:       "npm version not semver",
+			purlString: "pkg:npm/my-package@1.2" + checksum,
+			wantErrMsg: "npm pURL version must be a semantic version, was 1.2",
+		},
+		{
+			name:       "Valid maven pURL",
+			purlString: "pkg:maven/org.example/my-package@1.2" + checksum,
+		},
+		{
+			name:       "maven without groupId namespace",
+			purlString: "pkg:maven/my-package@1.2" + checksum,
+			wantErrMsg: "maven pURL must contain namespace",
+		},
+		{
+			name:       "Valid cargo pURL",
+			purlString: "pkg:cargo/my-package@1.2.3" + checksum,
+		},
+		{
+			name:       "cargo version with v prefix",
+			purlString: "pkg:cargo/my-package@v1.2.3" + checksum,
+			wantErrMsg: "cargo pURL version must be a semantic version, was v1.2.3",
+		},
+		{
+			name:       "Uppercase type",
+			purlString: "pkg:PYPI/my-package@1.2.3" + checksum,
+			wantErrMsg: "pURL must be in canonical form pkg:pypi/my-package@1.2.3",
+		},
+		{
+			name:       "Type without rules",
+			purlString: "pkg:generic/namespace/My_Package@v1" + checksum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(tt.purlString)
+			if tt.wantErrMsg == "" {
+				if err != nil {
+					t.Errorf("Verify() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+				t.Errorf("Verify() error = %v, should contain %v", err, tt.wantErrMsg)
+			}
+		})
+	}
+}
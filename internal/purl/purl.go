@@ -1,26 +1,90 @@
 package purl
 
 import (
-	"encoding/hex"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/package-url/packageurl-go"
 )
 
-// VerifyPURL verifies the pURL string is of the form
-// pkg:{type}/{optional namespace}/{name}@{version}?checksum=sha256:{checksum}
-func VerifyPURL(purlString, expectedPURLType string) error {
+// Rule checks type-specific requirements of a pURL, such as a package registry's naming conventions
+type Rule func(purl packageurl.PackageURL) error
+
+// Verifier verifies pURLs are one of a set of allowed types, satisfy the rules for their type,
+// and contain checksums allowed by the checksum policy
+type Verifier struct {
+	types     []string
+	rules     map[string][]Rule
+	checksums ChecksumPolicy
+}
+
+// NewVerifier returns a Verifier that accepts pURLs of the given types with SHA256 checksums
+func NewVerifier(types ...string) *Verifier {
+	v := &Verifier{rules: make(map[string][]Rule), checksums: DefaultChecksumPolicy}
+	for _, t := range types {
+		if _, ok := v.rules[t]; ok {
+			continue
+		}
+		v.types = append(v.types, t)
+		v.rules[t] = nil
+	}
+	return v
+}
+
+// WithRules adds rules for pURLs of the given type, which must be an allowed type
+func (v *Verifier) WithRules(purlType string, rules ...Rule) *Verifier {
+	if _, ok := v.rules[purlType]; ok {
+		v.rules[purlType] = append(v.rules[purlType], rules...)
+	}
+	return v
+}
+
+// WithChecksumPolicy sets the allowed checksum algorithms and minimum checksum strength.
+// Returns an error if an algorithm is unsupported or no algorithm meets the minimum strength.
+func (v *Verifier) WithChecksumPolicy(p ChecksumPolicy) (*Verifier, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	v.checksums = p
+	return v, nil
+}
+
+// Types returns the allowed pURL types
+func (v *Verifier) Types() []string {
+	return v.types
+}
+
+// Verify verifies the pURL string is of the form
+// pkg:{type}/{optional namespace}/{name}@{version}?checksum={algorithm}:{checksum},...,
+// that the type and checksum algorithms are allowed, and that the pURL satisfies the rules for its type
+func (v *Verifier) Verify(purlString string) error {
 	purl, err := packageurl.FromString(purlString)
 	if err != nil {
 		return err
 	}
-	if purl.Type != expectedPURLType {
-		return fmt.Errorf("pURL type must be %s, was %s", expectedPURLType, purl.Type)
+	rules, ok := v.rules[purl.Type]
+	if !ok {
+		return fmt.Errorf("pURL type must be %s, was %s", strings.Join(v.types, " or "), purl.Type)
 	}
 	if purl.Version == "" {
 		return fmt.Errorf("pURL must contain version")
 	}
+	// Reject pURLs that are only valid once normalized, e.g. a pypi name with uppercase characters,
+	// so that a package identity is always logged in the same form
+	path, _, _ := strings.Cut(strings.TrimPrefix(purlString, "pkg:"), "?")
+	path, _, _ = strings.Cut(path, "#")
+	rawID, err := url.PathUnescape(path)
+	if err != nil {
+		return err
+	}
+	canonicalID, err := url.PathUnescape(strings.TrimPrefix(PackageID(purl), "pkg:"))
+	if err != nil {
+		return err
+	}
+	if rawID != canonicalID {
+		return fmt.Errorf("pURL must be in canonical form pkg:%s, was pkg:%s", canonicalID, rawID)
+	}
 	qualifiers := purl.Qualifiers.Map()
 	if len(qualifiers) != 1 {
 		return fmt.Errorf("pURL must contain only the checksum qualifier")
@@ -29,21 +93,28 @@ func VerifyPURL(purlString, expectedPURLType string) error {
 	if !ok {
 		return fmt.Errorf("pURL missing checksum qualifier")
 	}
-	funcAndChecksum := strings.Split(checksum, ":")
-	if len(funcAndChecksum) != 2 {
-		return fmt.Errorf("pURL checksum must be sha256:hex-encoded-checksum")
-	}
-	if funcAndChecksum[0] != "sha256" {
-		return fmt.Errorf("pURL checksum must start with sha256")
-	}
-	if _, err := hex.DecodeString(funcAndChecksum[1]); err != nil {
-		return fmt.Errorf("pURL checksum must be hex-encoded")
-	}
-	if len(funcAndChecksum[1]) != 64 {
-		return fmt.Errorf("pURL checksum must be hex-encoded SHA256 checksum")
+	if err := verifyChecksums(checksum, v.checksums); err != nil {
+		return err
 	}
 	if purl.Subpath != "" {
 		return fmt.Errorf("pURL must not contain subpath")
 	}
+	for _, rule := range rules {
+		if err := rule(purl); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// VerifyPURL verifies the pURL string is of the form
+// pkg:{type}/{optional namespace}/{name}@{version}?checksum=sha256:{checksum}
+func VerifyPURL(purlString, expectedPURLType string) error {
+	return NewVerifier(expectedPURLType).Verify(purlString)
+}
+
+// PackageID returns the identity of a package version, which is the pURL without
+// qualifiers or subpath, e.g. pkg:pypi/pkgname@1.2.3
+func PackageID(purl packageurl.PackageURL) string {
+	return packageurl.NewPackageURL(purl.Type, purl.Namespace, purl.Name, purl.Version, nil, "").ToString()
+}
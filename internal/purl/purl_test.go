@@ -1,8 +1,12 @@
 package purl
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/package-url/packageurl-go"
 )
 
 func TestVerifyPURL(t *testing.T) {
@@ -116,3 +120,63 @@ func TestVerifyPURL(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifier(t *testing.T) {
+	noNamespace := func(purl packageurl.PackageURL) error {
+		if purl.Namespace != "" {
+			return fmt.Errorf("%s pURL must not contain namespace", purl.Type)
+		}
+		return nil
+	}
+	v := NewVerifier("npm", "pypi", "npm").WithRules("pypi", noNamespace).WithRules("deb", noNamespace)
+
+	if got := v.Types(); !reflect.DeepEqual(got, []string{"npm", "pypi"}) {
+		t.Errorf("Types() = %v, want [npm pypi]", got)
+	}
+
+	checksum := "?checksum=sha256:3b9730808f265c6d174662668435c4cf1fc9ddcd369831a646fa84bff8594f0c"
+	tests := []struct {
+		name       string
+		purlString string
+		wantErrMsg string
+	}{
+		{
+			name:       "First allowed type",
+			purlString: "pkg:npm/%40scope/my-package@1.2.3" + checksum,
+		},
+		{
+			name:       "Second allowed type",
+			purlString: "pkg:pypi/my-package@1.2.3" + checksum,
+		},
+		{
+			name:       "Disallowed type",
+			purlString: "pkg:deb/debian/my-package@1.2.3" + checksum,
+			wantErrMsg: "pURL type must be npm or pypi, was deb",
+		},
+		{
+			name:       "Type rule fails",
+			purlString: "pkg:pypi/namespace/my-package@1.2.3" + checksum,
+			wantErrMsg: "pypi pURL must not contain namespace",
+		},
+		{
+			name:       "Generic rule fails",
+			purlString: "pkg:npm/my-package" + checksum,
+			wantErrMsg: "pURL must contain version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(tt.purlString)
+			if tt.wantErrMsg == "" {
+				if err != nil {
+					t.Errorf("Verify() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+				t.Errorf("Verify() error = %v, should contain %v", err, tt.wantErrMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full buckets are removed, which bounds memory to the keys
+// seen since the last sweep that haven't refilled
+const sweepInterval = time.Minute
+
+// Limiter is a token bucket rate limiter per key, e.g. per client IP or submitter identity.
+// A nil Limiter allows all requests.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64 // Tokens added per second
+	burst     float64 // Bucket capacity
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter that refills each key's bucket at rate tokens per second,
+// up to burst tokens
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes n tokens from the key's bucket. n is capped at the burst size, so a request
+// larger than the burst needs a full bucket. If there are too few tokens, no tokens are taken,
+// and Allow returns false with the time until enough tokens are available.
+func (l *Limiter) Allow(key string, n int) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	cost := math.Min(float64(n), l.burst)
+	if b.tokens < cost {
+		wait := (cost - b.tokens) / l.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	b.tokens -= cost
+	return true, 0
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// sweep removes buckets that have refilled, since they're equivalent to a new bucket
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Quota limits the number of entries per key each day, resetting at midnight UTC. Counts are
+// kept in memory, so they reset when the process restarts.
+// A nil Quota allows all entries.
+type Quota struct {
+	mu     sync.Mutex
+	limit  int
+	day    time.Time
+	counts map[string]int
+	now    func() time.Time
+}
+
+// NewQuota returns a quota that allows limit entries per key each day
+func NewQuota(limit int) *Quota {
+	return &Quota{limit: limit, counts: make(map[string]int), now: time.Now}
+}
+
+// Allow counts an entry for the key. If the key's daily limit has been reached, the entry isn't
+// counted, and Allow returns false with the time until the quota resets.
+func (q *Quota) Allow(key string) (bool, time.Duration) {
+	if q == nil {
+		return true, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	// The zero time is midnight UTC, so truncating to a day gives the start of the UTC day
+	day := now.Truncate(24 * time.Hour)
+	if !day.Equal(q.day) {
+		q.day = day
+		clear(q.counts)
+	}
+	if q.counts[key] >= q.limit {
+		return false, day.Add(24 * time.Hour).Sub(now)
+	}
+	q.counts[key]++
+	return true, 0
+}
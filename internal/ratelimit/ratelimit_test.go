@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(2, 4)
+	l.now = func() time.Time { return now }
+
+	// A new key starts with a full bucket
+	for i := 0; i < 4; i++ {
+		if ok, _ := l.Allow("a", 1); !ok {
+			t.Fatalf("Allow() %d = false, want true", i)
+		}
+	}
+	ok, retryAfter := l.Allow("a", 1)
+	if ok || retryAfter != 500*time.Millisecond {
+		t.Errorf("Allow() with empty bucket = %v, %v, want false, 500ms", ok, retryAfter)
+	}
+
+	// Keys have separate buckets
+	if ok, _ := l.Allow("b", 1); !ok {
+		t.Errorf("Allow() for another key = false, want true")
+	}
+
+	// Tokens refill at the rate
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("a", 2); !ok {
+		t.Errorf("Allow() after refill = false, want true")
+	}
+	if ok, _ := l.Allow("a", 1); ok {
+		t.Errorf("Allow() after using refilled tokens = true, want false")
+	}
+
+	// Requests larger than the burst need a full bucket
+	now = now.Add(time.Second)
+	ok, retryAfter = l.Allow("a", 10)
+	if ok || retryAfter != time.Second {
+		t.Errorf("Allow() larger than burst with partial bucket = %v, %v, want false, 1s", ok, retryAfter)
+	}
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("a", 10); !ok {
+		t.Errorf("Allow() larger than burst with full bucket = false, want true")
+	}
+
+	var nilLimiter *Limiter
+	if ok, _ := nilLimiter.Allow("a", 100); !ok {
+		t.Errorf("Allow() for nil limiter = false, want true")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a", 2)
+	l.Allow("b", 1)
+	now = now.Add(sweepInterval)
+	l.Allow("c", 1)
+	// a and b have refilled, so only c remains
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 1 {
+		t.Errorf("buckets after sweep = %v, want only c", l.buckets)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	now := time.Date(2025, 1, 1, 22, 0, 0, 0, time.UTC)
+	q := NewQuota(2)
+	q.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := q.Allow("pkg:npm/@scope"); !ok {
+			t.Fatalf("Allow() %d = false, want true", i)
+		}
+	}
+	ok, retryAfter := q.Allow("pkg:npm/@scope")
+	if ok || retryAfter != 2*time.Hour {
+		t.Errorf("Allow() over quota = %v, %v, want false, 2h", ok, retryAfter)
+	}
+	if ok, _ := q.Allow("pkg:npm/@other"); !ok {
+		t.Errorf("Allow() for another key = false, want true")
+	}
+
+	// Quotas reset at midnight UTC
+	now = time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	if ok, _ := q.Allow("pkg:npm/@scope"); !ok {
+		t.Errorf("Allow() on the next day = false, want true")
+	}
+
+	var nilQuota *Quota
+	if ok, _ := nilQuota.Allow("pkg:npm/@scope"); !ok {
+		t.Errorf("Allow() for nil quota = false, want true")
+	}
+}
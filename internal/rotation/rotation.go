@@ -0,0 +1,151 @@
+package rotation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// header is the first line of every rotation statement. It ensures a statement, which is signed
+// by the log's checkpoint keys, can never be parsed as a checkpoint or an inclusion promise.
+const header = "bt-log/key-rotation/v1"
+
+// Statement is a log's endorsement of a new checkpoint signing key by its previous key. Monitors
+// and witnesses that trust the old key can use it to trust the new key.
+type Statement struct {
+	Origin string
+	// OldKey and NewKey are note verifier keys, both named by the origin
+	OldKey string
+	NewKey string
+}
+
+// Marshal returns the statement in its note text format:
+//
+//	bt-log/key-rotation/v1
+//	<origin>
+//	<old verifier key>
+//	<new verifier key>
+func (s Statement) Marshal() []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%s\n%s\n", header, s.Origin, s.OldKey, s.NewKey))
+}
+
+// Unmarshal parses a statement from its note text format
+func (s *Statement) Unmarshal(text []byte) error {
+	lines := strings.Split(string(text), "\n")
+	if len(lines) != 5 || lines[4] != "" {
+		return fmt.Errorf("rotation statement must contain 4 newline-terminated lines")
+	}
+	if lines[0] != header {
+		return fmt.Errorf("rotation statement header must be %s, was %s", header, lines[0])
+	}
+	st := Statement{Origin: lines[1], OldKey: lines[2], NewKey: lines[3]}
+	if _, _, err := st.verifiers(); err != nil {
+		return err
+	}
+	*s = st
+	return nil
+}
+
+// verifiers returns verifiers for the old and new keys, which must be distinct keys for the origin
+func (s Statement) verifiers() (note.Verifier, note.Verifier, error) {
+	if s.Origin == "" {
+		return nil, nil, fmt.Errorf("rotation statement missing origin")
+	}
+	if s.OldKey == s.NewKey {
+		return nil, nil, fmt.Errorf("rotation statement must rotate to a different key")
+	}
+	oldV, err := note.NewVerifier(s.OldKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing old key: %w", err)
+	}
+	newV, err := note.NewVerifier(s.NewKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing new key: %w", err)
+	}
+	if oldV.Name() != s.Origin || newV.Name() != s.Origin {
+		return nil, nil, fmt.Errorf("rotation statement keys must be named %s", s.Origin)
+	}
+	return oldV, newV, nil
+}
+
+// Sign returns the statement as a note signed by both the old and new keys. The new key's
+// signature shows the log holds the key that the old key endorses.
+func Sign(s Statement, oldSigner, newSigner note.Signer) ([]byte, error) {
+	oldV, newV, err := s.verifiers()
+	if err != nil {
+		return nil, err
+	}
+	if oldSigner.Name() != s.Origin || oldSigner.KeyHash() != oldV.KeyHash() {
+		return nil, fmt.Errorf("old signer does not match the statement's old key")
+	}
+	if newSigner.Name() != s.Origin || newSigner.KeyHash() != newV.KeyHash() {
+		return nil, fmt.Errorf("new signer does not match the statement's new key")
+	}
+	return note.Sign(&note.Note{Text: string(s.Marshal())}, oldSigner, newSigner)
+}
+
+// Parse verifies the signed note was signed by both keys it names and returns the parsed
+// statement. It doesn't check that the old key is trusted.
+func Parse(msg []byte) (*Statement, error) {
+	// The keys are read from the unverified text, then both must have signed it
+	n, err := note.Open(msg, note.VerifierList())
+	if n == nil {
+		var unverified *note.UnverifiedNoteError
+		if !errors.As(err, &unverified) {
+			return nil, err
+		}
+		n = unverified.Note
+	}
+	var s Statement
+	if err := s.Unmarshal([]byte(n.Text)); err != nil {
+		return nil, err
+	}
+	oldV, newV, err := s.verifiers()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range []note.Verifier{oldV, newV} {
+		if _, err := note.Open(msg, note.VerifierList(v)); err != nil {
+			return nil, fmt.Errorf("rotation statement not signed by %s: %w", v.Name(), err)
+		}
+	}
+	return &s, nil
+}
+
+// Verify verifies the signed note endorses a new key from the trusted verifier key, and returns
+// the parsed statement
+func Verify(msg []byte, trusted string) (*Statement, error) {
+	s, err := Parse(msg)
+	if err != nil {
+		return nil, err
+	}
+	if s.OldKey != strings.TrimSpace(trusted) {
+		return nil, fmt.Errorf("rotation statement endorses a key rotation from %s, not the trusted key", s.OldKey)
+	}
+	return s, nil
+}
+
+// Follow returns the latest key reachable from the trusted verifier key through the signed
+// statements, in any order, or the trusted key if no statement endorses a rotation from it.
+// Statements that don't verify are skipped.
+func Follow(trusted string, statements [][]byte) string {
+	trusted = strings.TrimSpace(trusted)
+	seen := map[string]bool{trusted: true}
+	for {
+		rotated := false
+		for _, msg := range statements {
+			s, err := Verify(msg, trusted)
+			if err != nil || seen[s.NewKey] {
+				continue
+			}
+			trusted, rotated = s.NewKey, true
+			seen[trusted] = true
+			break
+		}
+		if !rotated {
+			return trusted
+		}
+	}
+}
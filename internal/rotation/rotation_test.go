@@ -0,0 +1,112 @@
+package rotation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+const origin = "example.com/log"
+
+func generateKey(t *testing.T, name string) (note.Signer, string) {
+	t.Helper()
+	skey, vkey, err := note.GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	s, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return s, vkey
+}
+
+func sign(t *testing.T, oldSigner note.Signer, oldKey string, newSigner note.Signer, newKey string) []byte {
+	t.Helper()
+	msg, err := Sign(Statement{Origin: origin, OldKey: oldKey, NewKey: newKey}, oldSigner, newSigner)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return msg
+}
+
+func TestSignVerify(t *testing.T) {
+	oldSigner, oldKey := generateKey(t, origin)
+	newSigner, newKey := generateKey(t, origin)
+	msg := sign(t, oldSigner, oldKey, newSigner, newKey)
+
+	got, err := Verify(msg, oldKey+"\n")
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got.Origin != origin || got.OldKey != oldKey || got.NewKey != newKey {
+		t.Errorf("Verify() = %+v", got)
+	}
+	if _, err := Verify(msg, newKey); err == nil {
+		t.Errorf("Verify() with untrusted old key expected error, got nil")
+	}
+
+	// Both keys must sign
+	for name, signers := range map[string][]note.Signer{
+		"old key only": {oldSigner},
+		"new key only": {newSigner},
+	} {
+		unsigned, err := note.Sign(&note.Note{Text: string(Statement{Origin: origin, OldKey: oldKey, NewKey: newKey}.Marshal())}, signers...)
+		if err != nil {
+			t.Fatalf("note.Sign() error = %v", err)
+		}
+		if _, err := Verify(unsigned, oldKey); err == nil {
+			t.Errorf("Verify() signed with %s expected error, got nil", name)
+		}
+	}
+	if _, err := Verify(bytes.Replace(msg, []byte(origin+"\n"), []byte("example.com/other\n"), 1), oldKey); err == nil {
+		t.Errorf("Verify() with modified statement expected error, got nil")
+	}
+
+	otherSigner, otherKey := generateKey(t, "example.com/other")
+	for name, tc := range map[string]struct {
+		s                    Statement
+		oldSigner, newSigner note.Signer
+	}{
+		"same key":           {Statement{Origin: origin, OldKey: oldKey, NewKey: oldKey}, oldSigner, oldSigner},
+		"swapped signers":    {Statement{Origin: origin, OldKey: oldKey, NewKey: newKey}, newSigner, oldSigner},
+		"different origin":   {Statement{Origin: origin, OldKey: oldKey, NewKey: otherKey}, oldSigner, otherSigner},
+		"missing origin":     {Statement{OldKey: oldKey, NewKey: newKey}, oldSigner, newSigner},
+		"invalid public key": {Statement{Origin: origin, OldKey: oldKey, NewKey: "not a key"}, oldSigner, newSigner},
+	} {
+		if _, err := Sign(tc.s, tc.oldSigner, tc.newSigner); err == nil {
+			t.Errorf("Sign() with %s expected error, got nil", name)
+		}
+	}
+}
+
+func TestFollow(t *testing.T) {
+	aSigner, a := generateKey(t, origin)
+	bSigner, b := generateKey(t, origin)
+	cSigner, c := generateKey(t, origin)
+	_, d := generateKey(t, origin)
+
+	ab := sign(t, aSigner, a, bSigner, b)
+	bc := sign(t, bSigner, b, cSigner, c)
+	ca := sign(t, cSigner, c, aSigner, a)
+	for name, tc := range map[string]struct {
+		trusted    string
+		statements [][]byte
+		want       string
+	}{
+		"no statements":        {a, nil, a},
+		"single rotation":      {a, [][]byte{ab}, b},
+		"chain out of order":   {a, [][]byte{bc, ab}, c},
+		"from the middle":      {b, [][]byte{ab, bc}, c},
+		"unendorsed key":       {d, [][]byte{ab, bc}, d},
+		"cycle":                {a, [][]byte{ab, bc, ca}, c},
+		"invalid statement":    {a, [][]byte{[]byte("not a statement"), ab}, b},
+		"latest key unchanged": {c, [][]byte{ab, bc}, c},
+	} {
+		if got := Follow(tc.trusted, tc.statements); got != tc.want {
+			t.Errorf("Follow() with %s = %s, want %s", name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package witness
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/transparency-dev/tessera"
+)
+
+// Policy is the set of witnesses that must cosign a checkpoint before it's published.
+// Witnesses are combined into groups with a threshold, and groups may contain other groups,
+// e.g. a quorum of 2 of 3 operators, where each operator runs several witnesses.
+type Policy struct {
+	Witnesses []Witness `json:"witnesses"`
+	// Groups may only contain witnesses and groups defined before them
+	Groups []Group `json:"groups"`
+	// Quorum is the name of the group or witness that must be satisfied to publish a checkpoint
+	Quorum string `json:"quorum"`
+	// FailOpen publishes a checkpoint with the cosignatures that were collected when the quorum
+	// can't be satisfied. Otherwise, no checkpoint is published until the quorum is satisfied.
+	FailOpen bool `json:"fail_open,omitempty"`
+}
+
+// Witness is a witness that is asked to cosign each checkpoint
+type Witness struct {
+	Name string `json:"name"`
+	// PublicKey is the witness's note verifier key
+	PublicKey string `json:"public_key"`
+	// URL is the witness's base URL, to which /add-checkpoint is appended
+	URL string `json:"url"`
+}
+
+// Group is satisfied when at least Threshold of its members have cosigned a checkpoint. Groups
+// can't fail open, since a group that's counted towards another group's threshold without being
+// satisfied would weaken the quorum; only the policy as a whole can.
+type Group struct {
+	Name      string   `json:"name"`
+	Threshold int      `json:"threshold"`
+	Members   []string `json:"members"`
+}
+
+// LoadPolicy reads and validates a JSON witness policy file
+func LoadPolicy(p string) (*Policy, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read witness policy file: %w", err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse witness policy file: %w", err)
+	}
+	// Groups used to have a fail_open setting, which is rejected rather than ignored
+	var raw struct {
+		Groups []map[string]json.RawMessage `json:"groups"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse witness policy file: %w", err)
+	}
+	for i, g := range raw.Groups {
+		if _, ok := g["fail_open"]; ok {
+			return nil, fmt.Errorf("group %s sets fail_open, which is only supported for the whole policy", policy.Groups[i].Name)
+		}
+	}
+	if _, _, err := policy.WitnessGroup(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// WitnessGroup returns the Tessera witness group for the quorum, and whether a checkpoint
+// should be published when the quorum can't be satisfied
+func (p *Policy) WitnessGroup() (tessera.WitnessGroup, bool, error) {
+	// Components are tessera.Witness or tessera.WitnessGroup
+	components := make(map[string]any)
+	for _, w := range p.Witnesses {
+		if w.Name == "" {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("witness missing name")
+		}
+		if _, ok := components[w.Name]; ok {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("duplicate witness or group name %s", w.Name)
+		}
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("witness %s has invalid URL %q", w.Name, w.URL)
+		}
+		wit, err := tessera.NewWitness(w.PublicKey, u)
+		if err != nil {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("witness %s has invalid public key: %w", w.Name, err)
+		}
+		components[w.Name] = wit
+	}
+
+	for _, g := range p.Groups {
+		if g.Name == "" {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("group missing name")
+		}
+		if _, ok := components[g.Name]; ok {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("duplicate witness or group name %s", g.Name)
+		}
+		if g.Threshold < 1 || g.Threshold > len(g.Members) {
+			return tessera.WitnessGroup{}, false, fmt.Errorf("group %s threshold must be between 1 and its %d members", g.Name, len(g.Members))
+		}
+		// WitnessGroup.Components has an unexported element type, so it's built by appending
+		wg := tessera.WitnessGroup{N: g.Threshold}
+		seen := make(map[string]bool)
+		for _, m := range g.Members {
+			if seen[m] {
+				return tessera.WitnessGroup{}, false, fmt.Errorf("group %s lists member %s more than once", g.Name, m)
+			}
+			seen[m] = true
+			switch c := components[m].(type) {
+			case tessera.Witness:
+				wg.Components = append(wg.Components, c)
+			case tessera.WitnessGroup:
+				wg.Components = append(wg.Components, c)
+			default:
+				return tessera.WitnessGroup{}, false, fmt.Errorf("group %s member %s must be a witness or a group defined before it", g.Name, m)
+			}
+		}
+		components[g.Name] = wg
+	}
+
+	var quorum tessera.WitnessGroup
+	switch c := components[p.Quorum].(type) {
+	case tessera.Witness:
+		quorum = tessera.NewWitnessGroup(1, c)
+	case tessera.WitnessGroup:
+		quorum = c
+	default:
+		return tessera.WitnessGroup{}, false, fmt.Errorf("quorum %q must be the name of a witness or group", p.Quorum)
+	}
+	return quorum, p.FailOpen, nil
+}
@@ -0,0 +1,208 @@
+package witness
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	f_note "github.com/transparency-dev/formats/note"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const checkpoint = "binarytransparency.log/example\n10\n47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=\n"
+
+func writePolicy(t *testing.T, p Policy) string {
+	t.Helper()
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("failed to marshal policy: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("error writing policy: %v", err)
+	}
+	return path
+}
+
+// newWitness returns a witness and a signer for its cosignatures
+func newWitness(t *testing.T, name string) (Witness, note.Signer) {
+	t.Helper()
+	skey, vkey, err := note.GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	s, err := f_note.NewSignerForCosignatureV1(skey)
+	if err != nil {
+		t.Fatalf("failed to create cosigner: %v", err)
+	}
+	return Witness{Name: name, PublicKey: vkey, URL: "https://" + name + ".example.com"}, s
+}
+
+func cosign(t *testing.T, signers ...note.Signer) []byte {
+	t.Helper()
+	cp, err := note.Sign(&note.Note{Text: checkpoint}, signers...)
+	if err != nil {
+		t.Fatalf("failed to cosign checkpoint: %v", err)
+	}
+	return cp
+}
+
+func TestWitnessGroup(t *testing.T) {
+	a, aSigner := newWitness(t, "a")
+	b, bSigner := newWitness(t, "b")
+	c, cSigner := newWitness(t, "c")
+	d, dSigner := newWitness(t, "d")
+	p, err := LoadPolicy(writePolicy(t, Policy{
+		Witnesses: []Witness{a, b, c, d},
+		Groups: []Group{
+			{Name: "operators", Threshold: 2, Members: []string{"a", "b", "c"}},
+			{Name: "quorum", Threshold: 2, Members: []string{"operators", "d"}},
+		},
+		Quorum: "quorum",
+	}))
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	wg, failOpen, err := p.WitnessGroup()
+	if err != nil {
+		t.Fatalf("WitnessGroup() error = %v", err)
+	}
+	if failOpen {
+		t.Errorf("WitnessGroup() fail open = true, want false")
+	}
+	if got := len(wg.Endpoints()); got != 4 {
+		t.Errorf("WitnessGroup() has %d endpoints, want 4", got)
+	}
+
+	for name, tc := range map[string]struct {
+		signers []note.Signer
+		want    bool
+	}{
+		"2 of 3 operators and d": {[]note.Signer{aSigner, cSigner, dSigner}, true},
+		"all witnesses":          {[]note.Signer{aSigner, bSigner, cSigner, dSigner}, true},
+		"1 of 3 operators and d": {[]note.Signer{bSigner, dSigner}, false},
+		"3 of 3 operators":       {[]note.Signer{aSigner, bSigner, cSigner}, false},
+	} {
+		if got := wg.Satisfied(cosign(t, tc.signers...)); got != tc.want {
+			t.Errorf("Satisfied() with %s = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestWitnessGroupFailOpen(t *testing.T) {
+	a, aSigner := newWitness(t, "a")
+	b, bSigner := newWitness(t, "b")
+	c, _ := newWitness(t, "c")
+
+	// A fail-open policy is published without cosignatures when the quorum can't be satisfied,
+	// but still requires the quorum's threshold to be satisfied
+	p := Policy{
+		Witnesses: []Witness{a, b, c},
+		Groups: []Group{
+			{Name: "best-effort", Threshold: 1, Members: []string{"b", "c"}},
+			{Name: "quorum", Threshold: 2, Members: []string{"a", "best-effort"}},
+		},
+		Quorum:   "quorum",
+		FailOpen: true,
+	}
+	path := writePolicy(t, p)
+	loaded, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	wg, failOpen, err := loaded.WitnessGroup()
+	if err != nil {
+		t.Fatalf("WitnessGroup() error = %v", err)
+	}
+	if !failOpen {
+		t.Errorf("WitnessGroup() fail open = false, want true")
+	}
+	// The nested group only counts once it's satisfied
+	if wg.Satisfied(cosign(t, aSigner)) {
+		t.Errorf("Satisfied() without nested group = true, want false")
+	}
+	if !wg.Satisfied(cosign(t, aSigner, bSigner)) {
+		t.Errorf("Satisfied() with nested group = false, want true")
+	}
+	if got := len(wg.Endpoints()); got != 3 {
+		t.Errorf("WitnessGroup() has %d endpoints, want 3", got)
+	}
+
+	p.FailOpen = false
+	if _, failOpen, err := p.WitnessGroup(); err != nil || failOpen {
+		t.Errorf("WitnessGroup() without fail open = %t, %v, want fail closed", failOpen, err)
+	}
+
+	// A single witness can be the quorum
+	p.Quorum = "a"
+	wg, _, err = p.WitnessGroup()
+	if err != nil {
+		t.Fatalf("WitnessGroup() for witness quorum error = %v", err)
+	}
+	if !wg.Satisfied(cosign(t, aSigner)) {
+		t.Errorf("Satisfied() for witness quorum = false, want true")
+	}
+
+	// Groups can't fail open, whether or not they're the quorum
+	for _, group := range []string{"best-effort", "quorum"} {
+		var raw map[string]any
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read policy: %v", err)
+		}
+		if err := json.Unmarshal(b, &raw); err != nil {
+			t.Fatalf("failed to parse policy: %v", err)
+		}
+		for _, g := range raw["groups"].([]any) {
+			if g := g.(map[string]any); g["name"] == group {
+				g["fail_open"] = true
+			}
+		}
+		if b, err = json.Marshal(raw); err != nil {
+			t.Fatalf("failed to marshal policy: %v", err)
+		}
+		groupPath := filepath.Join(t.TempDir(), "policy.json")
+		if err := os.WriteFile(groupPath, b, 0o600); err != nil {
+			t.Fatalf("error writing policy: %v", err)
+		}
+		if _, err := LoadPolicy(groupPath); err == nil || !strings.Contains(err.Error(), "fail_open") {
+			t.Errorf("LoadPolicy() with fail-open group %s error = %v, want fail_open error", group, err)
+		}
+	}
+}
+
+func TestLoadPolicyInvalid(t *testing.T) {
+	a, _ := newWitness(t, "a")
+	b, _ := newWitness(t, "b")
+	badURL := a
+	badURL.URL = "not a url"
+	badKey := a
+	badKey.PublicKey = "a+1234+AAAA"
+	for name, p := range map[string]Policy{
+		"missing quorum":         {Witnesses: []Witness{a}},
+		"unknown quorum":         {Witnesses: []Witness{a}, Quorum: "b"},
+		"invalid URL":            {Witnesses: []Witness{badURL}, Quorum: "a"},
+		"invalid key":            {Witnesses: []Witness{badKey}, Quorum: "a"},
+		"duplicate witness":      {Witnesses: []Witness{a, a}, Quorum: "a"},
+		"threshold too high":     {Witnesses: []Witness{a, b}, Groups: []Group{{Name: "g", Threshold: 3, Members: []string{"a", "b"}}}, Quorum: "g"},
+		"zero threshold":         {Witnesses: []Witness{a, b}, Groups: []Group{{Name: "g", Threshold: 0, Members: []string{"a", "b"}}}, Quorum: "g"},
+		"unknown member":         {Witnesses: []Witness{a}, Groups: []Group{{Name: "g", Threshold: 1, Members: []string{"c"}}}, Quorum: "g"},
+		"repeated member":        {Witnesses: []Witness{a}, Groups: []Group{{Name: "g", Threshold: 2, Members: []string{"a", "a"}}}, Quorum: "g"},
+		"group named as witness": {Witnesses: []Witness{a}, Groups: []Group{{Name: "a", Threshold: 1, Members: []string{"a"}}}, Quorum: "a"},
+		"member defined later": {
+			Witnesses: []Witness{a},
+			Groups: []Group{
+				{Name: "g1", Threshold: 1, Members: []string{"g2"}},
+				{Name: "g2", Threshold: 1, Members: []string{"a"}},
+			},
+			Quorum: "g1",
+		},
+	} {
+		if _, err := LoadPolicy(writePolicy(t, p)); err == nil {
+			t.Errorf("LoadPolicy() with %s expected error, got nil", name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package witness
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/config"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// defaultPullInterval is how often logs are pulled if the interval isn't set
+const defaultPullInterval = time.Minute
+
+// PullConfig lists logs that a witness fetches checkpoints from, rather than waiting for the
+// logs to request cosignatures
+type PullConfig struct {
+	// Interval is how often each log's latest checkpoint is fetched
+	Interval config.Duration `json:"interval"`
+	// WebhookURL is an optional URL that each new cosigned checkpoint note is posted to
+	WebhookURL string    `json:"webhook_url,omitempty"`
+	Logs       []PullLog `json:"logs"`
+}
+
+// PullLog is a log that a witness pulls checkpoints from
+type PullLog struct {
+	Origin string `json:"origin"`
+	// PublicKey is the log's note verifier key, which must be named by the origin
+	PublicKey string `json:"public_key"`
+	// URL is the log's base URL, from which /checkpoint and tiles are fetched
+	URL string `json:"url"`
+}
+
+// LoadPullConfig reads and validates a JSON pull config file
+func LoadPullConfig(p string) (*PullConfig, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pull config file: %w", err)
+	}
+	var c PullConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse pull config file: %w", err)
+	}
+	if c.Interval.Duration == 0 {
+		c.Interval.Duration = defaultPullInterval
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate returns an error if the config has no logs, a log is invalid, or the interval is
+// shorter than a second
+func (c *PullConfig) Validate() error {
+	if c.Interval.Duration < time.Second {
+		return fmt.Errorf("pull interval must be at least 1s, was %s", c.Interval.Duration)
+	}
+	if c.WebhookURL != "" && !validURL(c.WebhookURL) {
+		return fmt.Errorf("invalid webhook URL %q", c.WebhookURL)
+	}
+	if len(c.Logs) == 0 {
+		return fmt.Errorf("pull config must list at least one log")
+	}
+	seen := make(map[string]bool)
+	for _, l := range c.Logs {
+		if seen[l.Origin] {
+			return fmt.Errorf("log %s is listed more than once", l.Origin)
+		}
+		seen[l.Origin] = true
+		v, err := note.NewVerifier(l.PublicKey)
+		if err != nil {
+			return fmt.Errorf("log %s has invalid public key: %w", l.Origin, err)
+		}
+		if v.Name() != l.Origin {
+			return fmt.Errorf("log %s public key must be named by its origin, was %s", l.Origin, v.Name())
+		}
+		if !validURL(l.URL) {
+			return fmt.Errorf("log %s has invalid URL %q", l.Origin, l.URL)
+		}
+	}
+	return nil
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
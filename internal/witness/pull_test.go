@@ -0,0 +1,84 @@
+package witness
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/haydentherapper/bt-log/internal/config"
+	"golang.org/x/mod/sumdb/note"
+)
+
+func writePullConfig(t *testing.T, c PullConfig) string {
+	t.Helper()
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("failed to marshal pull config: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "pull.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("error writing pull config: %v", err)
+	}
+	return path
+}
+
+func newPullLog(t *testing.T, origin string) PullLog {
+	t.Helper()
+	_, vkey, err := note.GenerateKey(rand.Reader, origin)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return PullLog{Origin: origin, PublicKey: vkey, URL: "https://" + origin}
+}
+
+func TestLoadPullConfig(t *testing.T) {
+	l := newPullLog(t, "log.example.com")
+	c, err := LoadPullConfig(writePullConfig(t, PullConfig{Logs: []PullLog{l}}))
+	if err != nil {
+		t.Fatalf("LoadPullConfig() error = %v", err)
+	}
+	if c.Interval.Duration != time.Minute {
+		t.Errorf("LoadPullConfig() interval = %s, want default of 1m", c.Interval.Duration)
+	}
+	if len(c.Logs) != 1 || c.Logs[0] != l {
+		t.Errorf("LoadPullConfig() logs = %+v, want %+v", c.Logs, []PullLog{l})
+	}
+
+	c, err = LoadPullConfig(writePullConfig(t, PullConfig{
+		Interval:   config.Duration{Duration: 10 * time.Second},
+		WebhookURL: "https://hooks.example.com/checkpoints",
+		Logs:       []PullLog{l},
+	}))
+	if err != nil {
+		t.Fatalf("LoadPullConfig() error = %v", err)
+	}
+	if c.Interval.Duration != 10*time.Second || c.WebhookURL != "https://hooks.example.com/checkpoints" {
+		t.Errorf("LoadPullConfig() = %+v", c)
+	}
+}
+
+func TestLoadPullConfigInvalid(t *testing.T) {
+	l := newPullLog(t, "log.example.com")
+	otherName := l
+	otherName.Origin = "other.example.com"
+	badURL := l
+	badURL.URL = "log.example.com"
+	badKey := l
+	badKey.PublicKey = "log.example.com+1234+AAAA"
+	for name, c := range map[string]PullConfig{
+		"no logs":                 {},
+		"short interval":          {Interval: config.Duration{Duration: time.Millisecond}, Logs: []PullLog{l}},
+		"invalid webhook":         {WebhookURL: "not a url", Logs: []PullLog{l}},
+		"duplicate log":           {Logs: []PullLog{l, l}},
+		"key not named by origin": {Logs: []PullLog{otherName}},
+		"invalid URL":             {Logs: []PullLog{badURL}},
+		"invalid key":             {Logs: []PullLog{badKey}},
+	} {
+		if _, err := LoadPullConfig(writePullConfig(t, c)); err == nil {
+			t.Errorf("LoadPullConfig() with %s expected error, got nil", name)
+		}
+	}
+}
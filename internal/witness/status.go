@@ -0,0 +1,207 @@
+package witness
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	f_note "github.com/transparency-dev/formats/note"
+	"github.com/transparency-dev/tessera"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// maxErrorBody is the maximum length of a witness's error response that is recorded
+const maxErrorBody = 256
+
+// Tracker records the outcome of each cosigning request to a policy's witnesses. Tessera
+// doesn't report per-witness results, so Tracker is set as the transport of the HTTP client
+// that Tessera uses to request cosignatures.
+type Tracker struct {
+	next      http.RoundTripper
+	witnesses []*trackedWitness
+	now       func() time.Time
+
+	mu sync.Mutex // Guards each witness's requests
+}
+
+type trackedWitness struct {
+	name     string
+	url      string
+	verifier note.Verifier
+	requests requestStatus
+}
+
+type requestStatus struct {
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// Status is a witness's cosignature on a checkpoint, and the outcome of its most recent requests.
+// Times are zero if there has been no such request or cosignature.
+type Status struct {
+	Name        string
+	URL         string
+	Cosigned    bool
+	CosignedAt  time.Time
+	LastSuccess time.Time
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// NewTracker returns a Tracker for the policy's witnesses, which sends requests with next
+func NewTracker(p *Policy, next http.RoundTripper) (*Tracker, error) {
+	t := &Tracker{next: next, now: time.Now}
+	for _, w := range p.Witnesses {
+		u, err := url.Parse(w.URL)
+		if err != nil {
+			return nil, fmt.Errorf("witness %s has invalid URL %q", w.Name, w.URL)
+		}
+		// The request URL and cosignature verifier are the ones Tessera uses
+		wit, err := tessera.NewWitness(w.PublicKey, u)
+		if err != nil {
+			return nil, fmt.Errorf("witness %s has invalid public key: %w", w.Name, err)
+		}
+		t.witnesses = append(t.witnesses, &trackedWitness{name: w.Name, url: wit.URL, verifier: wit.Key})
+	}
+	return t, nil
+}
+
+// RoundTrip sends the request, recording the outcome if it's a request to a witness. Witnesses
+// may share a URL, so a cosignature is only recorded for the witness whose key signed it.
+func (t *Tracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	var ws []*trackedWitness
+	for _, w := range t.witnesses {
+		if w.url == req.URL.String() {
+			ws = append(ws, w)
+		}
+	}
+	if len(ws) == 0 {
+		return t.next.RoundTrip(req)
+	}
+	cp, req, err := requestCheckpoint(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Tessera cancels outstanding requests once the quorum is satisfied
+		return resp, err
+	case err != nil:
+		t.recordError(ws, err.Error())
+		return resp, err
+	case resp.StatusCode == http.StatusConflict && resp.Header.Get("Content-Type") == "text/x.tlog.size":
+		// The witness is behind the log's view of it, and the request will be retried with a
+		// proof from the witness's size. This is expected, e.g. after the log restarts.
+		return resp, err
+	}
+
+	// Read the body before recording the outcome, and restore it for Tessera to read
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			t.recordError(ws, err.Error())
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(body[:min(len(body), maxErrorBody)]))
+		t.recordError(ws, fmt.Sprintf("%s: %s", resp.Status, msg))
+		return resp, nil
+	}
+
+	// The response is the witness's cosignature on the checkpoint, as verified by Tessera
+	signed := make([]byte, 0, len(cp)+len(body))
+	signed = append(append(signed, cp...), body...)
+	var cosigned []*trackedWitness
+	for _, w := range ws {
+		if _, err := note.Open(signed, note.VerifierList(w.verifier)); err == nil {
+			cosigned = append(cosigned, w)
+		}
+	}
+	if len(cosigned) == 0 {
+		t.recordError(ws, "invalid cosignature")
+		return resp, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for _, w := range cosigned {
+		w.requests.lastSuccess = now
+	}
+	return resp, nil
+}
+
+// recordError records an error from a request to the witnesses
+func (t *Tracker) recordError(ws []*trackedWitness, msg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for _, w := range ws {
+		w.requests.lastError, w.requests.lastErrorAt = msg, now
+	}
+}
+
+// requestCheckpoint returns the checkpoint from an add-checkpoint request body, which follows
+// the old size line, any consistency proof lines and an empty line. The returned request has
+// an unread body to send.
+func requestCheckpoint(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil {
+		return nil, req, nil
+	}
+	var body []byte
+	var err error
+	if req.GetBody != nil {
+		var rc io.ReadCloser
+		if rc, err = req.GetBody(); err == nil {
+			body, err = io.ReadAll(rc)
+			_ = rc.Close()
+		}
+	} else {
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		// A RoundTripper mustn't modify the request, so the body is restored on a copy
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read witness request: %w", err)
+	}
+	_, cp, _ := bytes.Cut(body, []byte("\n\n"))
+	return cp, req, nil
+}
+
+// Status returns the status of each witness for the checkpoint, in policy order
+func (t *Tracker) Status(cp []byte) []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := make([]Status, 0, len(t.witnesses))
+	for _, w := range t.witnesses {
+		s := Status{
+			Name:        w.name,
+			URL:         w.url,
+			LastSuccess: w.requests.lastSuccess,
+			LastError:   w.requests.lastError,
+			LastErrorAt: w.requests.lastErrorAt,
+		}
+		if n, err := note.Open(cp, note.VerifierList(w.verifier)); err == nil && len(n.Sigs) > 0 {
+			s.Cosigned = true
+			if ts, err := f_note.CoSigV1Timestamp(n.Sigs[0]); err == nil {
+				s.CosignedAt = ts
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
@@ -0,0 +1,119 @@
+package witness
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// cosignature returns the signature lines of the signer's cosignature on the checkpoint
+func cosignature(t *testing.T, s note.Signer) []byte {
+	t.Helper()
+	return []byte(strings.TrimPrefix(string(cosign(t, s)), checkpoint+"\n"))
+}
+
+func TestTracker(t *testing.T) {
+	a, aSigner := newWitness(t, "a")
+	b, _ := newWitness(t, "b")
+	c, _ := newWitness(t, "c")
+	d, _ := newWitness(t, "d")
+	e, _ := newWitness(t, "e")
+	f, fSigner := newWitness(t, "f")
+	_, otherSigner := newWitness(t, "other")
+	aCosig, fCosig, otherCosig := cosignature(t, aSigner), cosignature(t, fSigner), cosignature(t, otherSigner)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok/add-checkpoint":
+			_, _ = w.Write(aCosig)
+		case "/behind/add-checkpoint":
+			w.Header().Set("Content-Type", "text/x.tlog.size")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("5\n"))
+		case "/shared/add-checkpoint":
+			_, _ = w.Write(fCosig)
+		case "/other/add-checkpoint":
+			_, _ = w.Write(otherCosig)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("unknown log\n"))
+		}
+	}))
+	defer srv.Close()
+
+	a.URL = srv.URL + "/ok"
+	b.URL = srv.URL + "/behind"
+	c.URL = srv.URL + "/forbidden"
+	d.URL = "http://127.0.0.1:1"
+	// e and f share a URL, which only returns f's cosignatures
+	e.URL = srv.URL + "/shared"
+	f.URL = srv.URL + "/shared"
+	g, _ := newWitness(t, "g")
+	g.URL = srv.URL + "/other"
+	tr, err := NewTracker(&Policy{Witnesses: []Witness{a, b, c, d, e, f, g}}, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("NewTracker() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	tr.now = func() time.Time { return now }
+
+	_, logSigner := newWitness(t, "log")
+	body := "old 0\n\n" + string(cosign(t, logSigner))
+	client := &http.Client{Transport: tr}
+	for _, u := range []string{a.URL, b.URL, c.URL, d.URL, e.URL, g.URL, srv.URL + "/not-a-witness"} {
+		resp, err := client.Post(u+"/add-checkpoint", "text/plain", strings.NewReader(body))
+		if err != nil {
+			continue
+		}
+		// Responses must still be readable
+		got, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil || len(got) == 0 {
+			t.Errorf("response body from %s = %q, %v, want body", u, got, err)
+		}
+	}
+
+	// Requests cancelled once the quorum is satisfied aren't errors
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/add-checkpoint", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := client.Do(req); err == nil {
+		t.Errorf("cancelled request expected error, got nil")
+	}
+	now = now.Add(-time.Minute)
+
+	statuses := tr.Status(cosign(t, aSigner))
+	if len(statuses) != 7 {
+		t.Fatalf("Status() returned %d statuses, want 7", len(statuses))
+	}
+	if s := statuses[0]; s.Name != "a" || !s.Cosigned || s.CosignedAt.IsZero() || !s.LastSuccess.Equal(now) || s.LastError != "" {
+		t.Errorf("Status() for cosigning witness = %+v", s)
+	}
+	if s := statuses[1]; s.Cosigned || !s.LastSuccess.IsZero() || s.LastError != "" {
+		t.Errorf("Status() for witness behind the log = %+v, want no success or error", s)
+	}
+	if s := statuses[2]; s.Cosigned || s.LastError != "403 Forbidden: unknown log" || !s.LastErrorAt.Equal(now) {
+		t.Errorf("Status() for witness rejecting the log = %+v, want 403 error at %v", s, now)
+	}
+	if s := statuses[3]; s.Cosigned || s.LastError == "" || !s.LastErrorAt.Equal(now) {
+		t.Errorf("Status() for unreachable witness = %+v, want error", s)
+	}
+	if s := statuses[4]; !s.LastSuccess.IsZero() || s.LastError != "" {
+		t.Errorf("Status() for witness sharing another witness's URL = %+v, want no success or error", s)
+	}
+	if s := statuses[5]; s.Name != "f" || !s.LastSuccess.Equal(now) || s.LastError != "" {
+		t.Errorf("Status() for witness with shared URL = %+v, want success", s)
+	}
+	if s := statuses[6]; !s.LastSuccess.IsZero() || s.LastError != "invalid cosignature" || !s.LastErrorAt.Equal(now) {
+		t.Errorf("Status() for witness returning another key's cosignature = %+v, want invalid cosignature", s)
+	}
+}